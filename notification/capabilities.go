@@ -0,0 +1,134 @@
+package notification
+
+import (
+	"html"
+	"strings"
+)
+
+// Capabilities represents the capabilities of a server.
+// See the specification for the meaning of the capabilities.
+type Capabilities struct {
+	Actions        bool     // "actions"
+	ActionIcons    bool     // "action-icons"
+	Body           bool     // "body"
+	BodyHyperlinks bool     // "body-hyperlinks"
+	BodyImages     bool     // "body-images"
+	BodyMarkup     bool     // "body-markup"
+	IconMulti      bool     // "icon-multi"
+	IconStatic     bool     // "icon-static"
+	Persistence    bool     // "persistence"
+	Sound          bool     // "sound"
+	Extra          []string // unknown and vendor-specific capabilities
+}
+
+// GetCapabilitiesTyped calls GetCapabilities() and returns the result
+// as Capabilities.
+func GetCapabilitiesTyped() (*Capabilities, error) {
+	list, err := GetCapabilities()
+	if err != nil {
+		return nil, err
+	}
+	var caps Capabilities
+	for _, c := range list {
+		switch c {
+		case "actions":
+			caps.Actions = true
+		case "action-icons":
+			caps.ActionIcons = true
+		case "body":
+			caps.Body = true
+		case "body-hyperlinks":
+			caps.BodyHyperlinks = true
+		case "body-images":
+			caps.BodyImages = true
+		case "body-markup":
+			caps.BodyMarkup = true
+		case "icon-multi":
+			caps.IconMulti = true
+		case "icon-static":
+			caps.IconStatic = true
+		case "persistence":
+			caps.Persistence = true
+		case "sound":
+			caps.Sound = true
+		default:
+			caps.Extra = append(caps.Extra, c)
+		}
+	}
+	return &caps, nil
+}
+
+// HasCapability reports whether the server has the capability c.
+func HasCapability(c string) (bool, error) {
+	list, err := GetCapabilities()
+	if err != nil {
+		return false, err
+	}
+	for _, name := range list {
+		if name == c {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// BuildBody joins lines into a body for a notification. If the server
+// supports the capability "body-markup", each line is escaped, so characters
+// like "<" and "&" are shown literally. The lines are separated by newlines
+// because the markup defined by the specification has no tag for line
+// breaks and servers honor newlines with and without markup.
+func BuildBody(lines []string) string {
+	markup, err := HasCapability("body-markup")
+	if err == nil && markup {
+		escaped := make([]string, len(lines))
+		for i, line := range lines {
+			escaped[i] = html.EscapeString(line)
+		}
+		lines = escaped
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ActionSupport describes how a server presents actions.
+type ActionSupport int
+
+const (
+	ActionsUnsupported ActionSupport = iota // actions are not supported
+	ActionsDefaultOnly                      // only the "default" action (clicking the notification) is usable
+	ActionsButtons                          // actions are shown as buttons with labels
+	ActionsIconButtons                      // actions can be shown as buttons with icons
+)
+
+// Servers which advertise the "actions" capability but do not show
+// buttons, e.g. dunst offers them only in a context menu.
+var defaultOnlyServers = map[string]bool{
+	"dunst": true,
+}
+
+// GetActionSupport returns how the server will probably present actions.
+// The specification does not provide this information, so it is derived
+// by these heuristics:
+//   - without the "actions" capability actions are not supported
+//   - servers known to not show buttons (e.g. dunst) support only the default action
+//   - with the "action-icons" capability actions can be shown with icons
+//   - otherwise actions are shown as buttons
+func GetActionSupport() (ActionSupport, error) {
+	caps, err := GetCapabilitiesTyped()
+	if err != nil {
+		return ActionsUnsupported, err
+	}
+	if !caps.Actions {
+		return ActionsUnsupported, nil
+	}
+	info, err := GetServerInformation()
+	if err != nil {
+		return ActionsUnsupported, err
+	}
+	if defaultOnlyServers[info.Name] {
+		return ActionsDefaultOnly, nil
+	}
+	if caps.ActionIcons {
+		return ActionsIconButtons, nil
+	}
+	return ActionsButtons, nil
+}
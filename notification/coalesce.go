@@ -0,0 +1,39 @@
+package notification
+
+import "time"
+
+type coalesceEntry struct {
+	id   uint32
+	sent time.Time
+}
+
+// Recently sent notifications by coalesceKey(); guarded by mu.
+var coalesced = make(map[string]coalesceEntry)
+
+func coalesceKey(appName string, noti *Notification) string {
+	return appName + "\x00" + noti.summary + "\x00" + noti.body
+}
+
+// coalescedID returns the ID of an active notification with the same
+// content which was sent within the window or 0.
+func coalescedID(appName string, noti *Notification) uint32 {
+	mu.Lock()
+	defer mu.Unlock()
+	e, ok := coalesced[coalesceKey(appName, noti)]
+	if ok && time.Since(e.sent) < conf.coalesce && notifications[e.id] != nil {
+		return e.id
+	}
+	return 0
+}
+
+// recordCoalesced records a sent notification and removes expired entries.
+// mu must be held.
+func recordCoalesced(appName string, noti *Notification) {
+	now := time.Now()
+	for key, e := range coalesced {
+		if now.Sub(e.sent) >= conf.coalesce {
+			delete(coalesced, key)
+		}
+	}
+	coalesced[coalesceKey(appName, noti)] = coalesceEntry{noti.id, now}
+}
@@ -0,0 +1,266 @@
+package notification
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/godbus/dbus"
+)
+
+// Hint keys defined by the specification.
+const (
+	HintActionIcons   = "action-icons"   // bool
+	HintCategory      = "category"       // string
+	HintDesktopEntry  = "desktop-entry"  // string
+	HintImageData     = "image-data"     // (iiibiiay)
+	HintImagePath     = "image-path"     // string
+	HintResident      = "resident"       // bool
+	HintSoundFile     = "sound-file"     // string
+	HintSoundName     = "sound-name"     // string
+	HintSuppressSound = "suppress-sound" // bool
+	HintTransient     = "transient"      // bool
+	HintX             = "x"              // int32
+	HintY             = "y"              // int32
+	HintUrgency       = "urgency"        // byte; use SetUrgency()
+)
+
+// HintValue is the key of the hint for the value of a progress bar.
+// It is not defined by the specification but supported by many servers.
+const HintValue = "value" // int32
+
+var (
+	defaultCategory     string // guarded by mu
+	defaultDesktopEntry string // guarded by mu
+	autoEntry           bool   // defaultDesktopEntry was derived by Init(); guarded by mu
+)
+
+// SetDefaultCategory sets the "category" hint which is sent with every
+// notification that does not set it itself.
+func SetDefaultCategory(category string) {
+	mu.Lock()
+	defaultCategory = category
+	mu.Unlock()
+}
+
+// DefaultCategory returns the default "category" hint.
+func DefaultCategory() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return defaultCategory
+}
+
+// SetDefaultDesktopEntry sets the "desktop-entry" hint which is sent with
+// every notification that does not set it itself (see also the option
+// WithAutoDesktopEntry()).
+func SetDefaultDesktopEntry(entry string) {
+	mu.Lock()
+	defaultDesktopEntry = entry
+	autoEntry = false
+	mu.Unlock()
+}
+
+// setAutoDesktopEntry sets the default "desktop-entry" hint derived with
+// WithAutoDesktopEntry() or clears it if entry is empty. A value set with
+// SetDefaultDesktopEntry() is kept.
+func setAutoDesktopEntry(entry string) {
+	mu.Lock()
+	if defaultDesktopEntry == "" || autoEntry {
+		defaultDesktopEntry = entry
+		autoEntry = entry != ""
+	}
+	mu.Unlock()
+}
+
+// DefaultDesktopEntry returns the default "desktop-entry" hint.
+func DefaultDesktopEntry() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return defaultDesktopEntry
+}
+
+// D-Bus signatures of the standard hints and of "value" which is
+// supported by many servers.
+var hintSignatures = map[string]string{
+	HintActionIcons:   "b",
+	HintCategory:      "s",
+	HintDesktopEntry:  "s",
+	HintImageData:     "(iiibiiay)",
+	HintImagePath:     "s",
+	HintResident:      "b",
+	HintSoundFile:     "s",
+	HintSoundName:     "s",
+	HintSuppressSound: "b",
+	HintTransient:     "b",
+	HintX:             "i",
+	HintY:             "i",
+	HintUrgency:       "y",
+	HintValue:         "i",
+}
+
+// Validate checks the types of the standard hints.
+// Servers usually reject a notification with a hint of the wrong type
+// without a helpful error message, e.g. if "x" was added as an int64
+// instead of an int32. Hints with unknown keys are not checked.
+func (noti *Notification) Validate() error {
+	var invalid []string
+	for key, value := range noti.hints {
+		sig, ok := hintSignatures[key]
+		if ok && value.Signature().String() != sig {
+			invalid = append(invalid, fmt.Sprintf("%s (%s, want %s)",
+				key, value.Signature(), sig))
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	sort.Strings(invalid)
+	return fmt.Errorf("notification: Invalid hint types: %s", strings.Join(invalid, ", "))
+}
+
+// SetHintString adds a hint with a string value.
+// This can be used for standard hints as well as for vendor-specific
+// hints (keys starting with "x-vendor-"). The specification does not
+// define a hint for the locale/language of a notification; servers which
+// support one document their own key.
+func (noti *Notification) SetHintString(key, value string) {
+	noti.setHint(key, value)
+}
+
+// SetHintBool adds a hint with a boolean value.
+func (noti *Notification) SetHintBool(key string, value bool) {
+	noti.setHint(key, value)
+}
+
+// SetHintByte adds a hint with a byte value.
+func (noti *Notification) SetHintByte(key string, value byte) {
+	noti.setHint(key, value)
+}
+
+// SetHintInt32 adds a hint with an int32 value.
+func (noti *Notification) SetHintInt32(key string, value int32) {
+	noti.setHint(key, value)
+}
+
+// SetHintStringArray adds a hint with a string array value (signature "as"),
+// e.g. "x-kde-urls".
+func (noti *Notification) SetHintStringArray(key string, value []string) {
+	noti.setHint(key, value)
+}
+
+// SetHintDict adds a hint with a dictionary value (signature "a{sv}").
+// Each value of the map is wrapped in a variant, so it may have any type
+// which can be sent over D-Bus.
+func (noti *Notification) SetHintDict(key string, value map[string]interface{}) {
+	dict := make(map[string]dbus.Variant, len(value))
+	for k, v := range value {
+		dict[k] = dbus.MakeVariant(v)
+	}
+	noti.setHint(key, dict)
+}
+
+// SetTransient sets the hint "transient". A transient notification
+// bypasses the server's persistence capability, i.e. it is not kept in
+// the history after it has been closed.
+func (noti *Notification) SetTransient(transient bool) {
+	noti.SetHintBool(HintTransient, transient)
+}
+
+// SetResident sets the hint "resident". A resident notification is not
+// removed automatically when an action is invoked; it stays until it is
+// closed by the user or with CloseNotification().
+//
+// Whether Notify() replaces or adds a notification only depends on its ID:
+// a notification which has been sent is replaced (if it is still shown)
+// unless ForceNew() is called first.
+func (noti *Notification) SetResident(resident bool) {
+	noti.SetHintBool(HintResident, resident)
+}
+
+// SetProgress sets the hint "value" to show a progress bar.
+// The percentage is clamped to the range 0 to 100; use SetValue() to
+// send other values.
+func (noti *Notification) SetProgress(percent int) {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	noti.SetValue(int32(percent))
+}
+
+// SetValue sets the hint "value" without restricting its range.
+// How values outside of 0 to 100 are shown depends on the server, e.g.
+// KDE Plasma shows values above 100 as an overfilled bar, others clamp them.
+func (noti *Notification) SetValue(value int32) {
+	noti.SetHintInt32(HintValue, value)
+}
+
+// SetCategory sets the "category" hint, e.g. "email.arrived" or
+// "transfer.complete". See the specification for the standard categories.
+func (noti *Notification) SetCategory(category string) {
+	noti.SetHintString(HintCategory, category)
+}
+
+// SetDesktopEntry sets the "desktop-entry" hint.
+// This is the name of the application's desktop file without the
+// ".desktop" suffix, e.g. "org.example.App".
+func (noti *Notification) SetDesktopEntry(entry string) {
+	noti.SetHintString(HintDesktopEntry, entry)
+}
+
+// SetImagePath sets the "image-path" hint.
+// The image is either a file path, which will be converted with FileURI(),
+// a URI or the name of an icon in the icon theme.
+func (noti *Notification) SetImagePath(image string) error {
+	if !strings.Contains(image, "://") && !isIconName(image) {
+		uri, err := FileURI(image)
+		if err != nil {
+			return err
+		}
+		image = uri
+	}
+	noti.SetHintString(HintImagePath, image)
+	return nil
+}
+
+// SetSoundFile sets the "sound-file" hint.
+// The path will be made absolute; a leading "~" is replaced with the
+// user's home directory.
+func (noti *Notification) SetSoundFile(path string) error {
+	abs, err := absPath(path)
+	if err != nil {
+		return err
+	}
+	noti.SetHintString(HintSoundFile, abs)
+	return nil
+}
+
+// SetSenderPID sets the hint "sender-pid" which is used by KDE Plasma to
+// associate a notification with the process (and its window) which sent it.
+func (noti *Notification) SetSenderPID(pid int64) {
+	noti.setHint("sender-pid", pid)
+}
+
+// SandboxAppID returns the app ID if the process runs inside a Flatpak
+// sandbox or an empty string otherwise.
+// The notification portal (org.freedesktop.portal.Notification) identifies
+// an application by this ID and servers may reject or misattribute
+// notifications whose "desktop-entry" hint does not match it; use it with
+// SetDesktopEntry() or the option WithAutoDesktopEntry().
+func SandboxAppID() string {
+	return os.Getenv("FLATPAK_ID")
+}
+
+// SetKDEURLs sets the KDE-specific hint "x-kde-urls".
+// This is a list of URLs attached to the notification which KDE Plasma
+// shows as clickable links, e.g. the downloaded file or its folder.
+// An empty slice will remove the hint.
+func (noti *Notification) SetKDEURLs(urls []string) {
+	if len(urls) == 0 {
+		delete(noti.hints, "x-kde-urls")
+	} else {
+		noti.SetHintStringArray("x-kde-urls", urls)
+	}
+}
@@ -0,0 +1,148 @@
+package notification
+
+import "testing"
+
+func TestHintSetterSignatures(t *testing.T) {
+	noti := New("summary", "body")
+	noti.SetHintString("x-vendor-string", "s")
+	noti.SetHintBool("x-vendor-bool", true)
+	noti.SetHintByte("x-vendor-byte", 1)
+	noti.SetHintInt32("x-vendor-int32", 1)
+	noti.SetHintStringArray("x-vendor-strings", []string{"a", "b"})
+	noti.SetHintDict("x-vendor-dict", map[string]interface{}{"a": 1})
+	noti.SetTransient(true)
+	noti.SetResident(true)
+	noti.SetProgress(50)
+	noti.SetCategory("transfer")
+	noti.SetDesktopEntry("org.example.App")
+	noti.SetSenderPID(42)
+	noti.SetKDEURLs([]string{"file:///tmp"})
+	tests := []struct {
+		key string
+		sig string
+	}{
+		{"x-vendor-string", "s"},
+		{"x-vendor-bool", "b"},
+		{"x-vendor-byte", "y"},
+		{"x-vendor-int32", "i"},
+		{"x-vendor-strings", "as"},
+		{"x-vendor-dict", "a{sv}"},
+		{HintTransient, "b"},
+		{HintResident, "b"},
+		{HintValue, "i"},
+		{HintCategory, "s"},
+		{HintDesktopEntry, "s"},
+		{"sender-pid", "x"},
+		{"x-kde-urls", "as"},
+	}
+	for _, tt := range tests {
+		v, ok := noti.hints[tt.key]
+		if !ok {
+			t.Errorf("hint %q not set", tt.key)
+		} else if sig := v.Signature().String(); sig != tt.sig {
+			t.Errorf("hint %q has signature %s, want %s", tt.key, sig, tt.sig)
+		}
+	}
+	if err := noti.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestSetProgress(t *testing.T) {
+	tests := []struct {
+		percent int
+		want    int32
+	}{
+		{-1, 0},
+		{0, 0},
+		{50, 50},
+		{100, 100},
+		{150, 100},
+	}
+	for _, tt := range tests {
+		noti := New("", "")
+		noti.SetProgress(tt.percent)
+		if v := noti.hints[HintValue].Value(); v != tt.want {
+			t.Errorf("SetProgress(%d) sets %v, want %d", tt.percent, v, tt.want)
+		}
+	}
+}
+
+func TestSetKDEURLsEmptyRemovesHint(t *testing.T) {
+	noti := New("", "")
+	noti.SetKDEURLs([]string{"file:///tmp"})
+	noti.SetKDEURLs(nil)
+	if _, ok := noti.hints["x-kde-urls"]; ok {
+		t.Error("SetKDEURLs(nil) did not remove the hint")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		key   string
+		value interface{}
+		ok    bool
+	}{
+		{HintX, int32(10), true},
+		{HintX, 10, true}, // godbus sends an int as int32
+		{HintX, int64(10), false},
+		{HintY, uint32(10), false},
+		{HintCategory, "transfer", true},
+		{HintCategory, []string{"transfer"}, false},
+		{HintTransient, true, true},
+		{HintTransient, "true", false},
+		{HintValue, int32(50), true},
+		{HintValue, int64(50), false},
+		{HintImageData, "image.png", false},
+		{"x-vendor-anything", 10, true},
+	}
+	for _, tt := range tests {
+		noti := New("", "")
+		noti.AddHint(tt.key, tt.value)
+		err := noti.Validate()
+		if tt.ok && err != nil {
+			t.Errorf("Validate() with %s=%#v: %v", tt.key, tt.value, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("Validate() with %s=%#v: no error", tt.key, tt.value)
+		}
+	}
+}
+
+func TestUrgencyHintIgnored(t *testing.T) {
+	noti := New("", "")
+	noti.SetUrgency(UrgencyCritical)
+	noti.AddHint(HintUrgency, byte(UrgencyLow))
+	noti.SetHintByte(HintUrgency, byte(UrgencyLow))
+	if _, ok := noti.hints[HintUrgency]; ok {
+		t.Error("urgency hint was added")
+	}
+	if v := noti.sendHints()[HintUrgency].Value(); v != UrgencyCritical {
+		t.Errorf("urgency hint is %v, want %v", v, UrgencyCritical)
+	}
+	noti.SetUrgency(UrgencyUnset)
+	if _, ok := noti.sendHints()[HintUrgency]; ok {
+		t.Error("urgency hint is sent with UrgencyUnset")
+	}
+}
+
+func TestAutoDesktopEntry(t *testing.T) {
+	defer SetDefaultDesktopEntry("")
+	SetDefaultDesktopEntry("")
+	setAutoDesktopEntry("auto")
+	if got := DefaultDesktopEntry(); got != "auto" {
+		t.Errorf("derived entry = %q, want %q", got, "auto")
+	}
+	setAutoDesktopEntry("")
+	if got := DefaultDesktopEntry(); got != "" {
+		t.Errorf("derived entry was not cleared: %q", got)
+	}
+	SetDefaultDesktopEntry("org.example.App")
+	setAutoDesktopEntry("auto")
+	if got := DefaultDesktopEntry(); got != "org.example.App" {
+		t.Errorf("entry set by the user was replaced with %q", got)
+	}
+	setAutoDesktopEntry("")
+	if got := DefaultDesktopEntry(); got != "org.example.App" {
+		t.Errorf("entry set by the user was cleared: %q", got)
+	}
+}
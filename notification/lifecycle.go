@@ -0,0 +1,43 @@
+package notification
+
+// LifecyclePhase is the phase of a notification's lifecycle.
+type LifecyclePhase int
+
+const (
+	LifecycleSent     LifecyclePhase = iota // the notification was sent with Notify()
+	LifecycleActioned                       // an action was invoked
+	LifecycleClosed                         // the notification was closed
+)
+
+// LifecycleEvent is passed to the function set with OnLifecycle().
+type LifecycleEvent struct {
+	Phase     LifecyclePhase
+	ID        uint32 // the notification's ID
+	ActionKey string // the key of the invoked action (LifecycleActioned)
+	Reason    Reason // the reason why the notification was closed (LifecycleClosed)
+}
+
+// OnLifecycle sets a function which is called when the notification is
+// sent, when an action is invoked and when it is closed. It is called in
+// addition to the action and closed handlers.
+// Setting handler to nil will remove the function.
+func (noti *Notification) OnLifecycle(handler func(ev LifecycleEvent)) {
+	mu.Lock()
+	noti.lifecycleHandler = handler
+	mu.Unlock()
+}
+
+// lifecycleEvent dispatches an event to the lifecycle handler if not nil.
+func lifecycleEvent(handler func(LifecycleEvent), ev LifecycleEvent) {
+	if handler != nil {
+		dispatch(func() { handler(ev) })
+	}
+}
+
+// postLifecycleEvent is like lifecycleEvent but for events which do not
+// come from the event loop (see post()).
+func postLifecycleEvent(handler func(LifecycleEvent), ev LifecycleEvent) {
+	if handler != nil {
+		post(func() { handler(ev) })
+	}
+}
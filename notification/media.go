@@ -0,0 +1,32 @@
+package notification
+
+import "fmt"
+
+// NewMediaNotification creates a notification for a media player which
+// shows the title and artist and the actions (e.g. play/pause and next)
+// as icons. The hints "resident" and "action-icons" are set, so the
+// notification stays when an action is invoked and the actions' IconName
+// (e.g. "media-playback-start") is sent as their key.
+// Use CheckMediaSupport() to find out whether the server can show it.
+func NewMediaNotification(title, artist string, actions []Action) *Notification {
+	noti := New(title, artist)
+	noti.SetResident(true)
+	noti.SetHintBool(HintActionIcons, true)
+	noti.SetActions(actions)
+	return noti
+}
+
+// CheckMediaSupport returns an error wrapping ErrUnsupported if the server
+// does not support the capabilities "actions" and "action-icons" which are
+// needed to show the controls of a notification created with
+// NewMediaNotification().
+func CheckMediaSupport() error {
+	caps, err := GetCapabilitiesTyped()
+	if err != nil {
+		return err
+	}
+	if !caps.Actions || !caps.ActionIcons {
+		return fmt.Errorf("%w: Actions with icons", ErrUnsupported)
+	}
+	return nil
+}
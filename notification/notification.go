@@ -6,16 +6,25 @@
 package notification
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net/url"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/godbus/dbus"
 )
 
 const (
-	PackageVersion  = "0.2.2"
+	PackageVersion  = "0.3.0"
 	ExpiresNever    = time.Duration(0)        // notification never expires
 	ExpiresDefault  = time.Duration(-1000000) // depends on the server's settings
 	busName         = "org.freedesktop.Notifications"
@@ -28,20 +37,110 @@ const (
 	ReasonUndefined = 4 // undefined/reserved reasons
 )
 
+// Reason is the reason why a notification was closed.
+// A closed handler can convert its argument with Reason(reason).
+type Reason uint32
+
+// String returns "expired", "dismissed", "closed" or "undefined" for the
+// Reason* constants and "undefined(<n>)" for other values which are
+// reserved by the specification. Closed handlers get such values unchanged.
+func (r Reason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonDismissed:
+		return "dismissed"
+	case ReasonClosed:
+		return "closed"
+	case ReasonUndefined:
+		return "undefined"
+	}
+	return fmt.Sprintf("undefined(%d)", uint32(r))
+}
+
+// IsUserDismissed reports whether the notification was dismissed by the user.
+func (r Reason) IsUserDismissed() bool {
+	return r == ReasonDismissed
+}
+
+// WasProgrammatic reports whether the notification was closed by a call
+// to CloseNotification.
+func (r Reason) WasProgrammatic() bool {
+	return r == ReasonClosed
+}
+
 type Urgency byte
 
 const (
 	UrgencyLow      Urgency = 0
 	UrgencyNormal   Urgency = 1
 	UrgencyCritical Urgency = 2
+	UrgencyUnset    Urgency = 255 // the urgency hint is not sent
 )
 
+var urgencyNames = []string{"low", "normal", "critical"}
+
+// String returns "low", "normal", "critical" or "unset".
+func (u Urgency) String() string {
+	if int(u) < len(urgencyNames) {
+		return urgencyNames[u]
+	}
+	if u == UrgencyUnset {
+		return "unset"
+	}
+	return fmt.Sprintf("Urgency(%d)", u)
+}
+
+// ParseUrgency returns the Urgency for "low", "normal", "critical" or
+// "unset" (case-insensitive).
+func ParseUrgency(s string) (Urgency, error) {
+	for i, name := range urgencyNames {
+		if strings.EqualFold(s, name) {
+			return Urgency(i), nil
+		}
+	}
+	if strings.EqualFold(s, "unset") {
+		return UrgencyUnset, nil
+	}
+	return 0, fmt.Errorf("notification: Invalid urgency: %q", s)
+}
+
+// ErrUnsupported is returned if the server does not implement a method,
+// e.g. on minimal systems where org.freedesktop.Notifications is registered
+// but not functional. Use errors.Is() to check for it.
+var ErrUnsupported = errors.New("notification: Not supported by the server")
+
+// ErrNoServer is returned by Available() if no notification server is
+// available and by calls to the server if the bus cannot reach it.
+var ErrNoServer = errors.New("notification: No notification server")
+
 var (
-	AppName       string
-	AppIcon       string
+	mu      sync.Mutex // guards notifications, the handler lists, AppName, AppIcon and the default hints
+	matchMu sync.Mutex // serializes adding handlers which need a match rule
+
+	// Deprecated: Assigning AppName directly is not safe for concurrent use;
+	// use SetAppName() and AppNameValue() instead.
+	AppName string
+
+	// Deprecated: Assigning AppIcon directly is not safe for concurrent use;
+	// use SetAppIcon() and AppIconValue() instead.
+	AppIcon string
+
 	busConn       *dbus.Conn
 	busObj        dbus.BusObject
 	notifications map[uint32]*Notification
+	dispatch      func(func())
+
+	signalHandlers map[string][]func(*dbus.Signal)
+	syncRequests   chan chan struct{} // see Sync()
+	loopDone       chan struct{}      // closed when the event loop stops
+	loopWake       chan struct{}      // wakes the event loop for posted functions; guarded by mu
+	serverName     string             // the bus name of the server; guarded by mu
+	serverOwner    string             // the unique name of its owner; guarded by mu
+	posted         []func()           // functions passed to post(); guarded by mu
+	fallback       Notifier           // used if the session bus is not available
+	usePortal      bool               // busObj is the notification portal
+	conf           config
 )
 
 // SendNotification sends a simple notification.
@@ -52,94 +151,708 @@ func SendNotification(summary, body, appName, appIcon string, urgency Urgency, t
 	}
 	obj := conn.Object(busName, objPath)
 	hints := make(map[string]dbus.Variant, 1)
-	hints["urgency"] = dbus.MakeVariant(urgency)
-	var icon string
-	if appIcon == "" {
-		icon = ""
-	} else {
-		icon, _ = filepath.Abs(appIcon)
+	if urgency != UrgencyUnset {
+		hints["urgency"] = dbus.MakeVariant(urgency)
 	}
-	call := obj.Call(busInterface+".Notify", 0, appName, uint32(0), icon, summary, body,
-		make([]string, 0), hints, int32(timeout.Seconds()*1000))
+	call := obj.Call(busInterface+".Notify", 0, appName, uint32(0), resolveIcon(appIcon), summary, body,
+		make([]string, 0), hints, timeoutMillis(timeout))
 	if call.Err != nil {
-		return fmt.Errorf("notification: %w", call.Err)
+		return callError(call.Err)
 	}
 	return nil
 }
 
-// Init connects to the session bus, sets the appName and appIcon and
-// starts an event loop.
-func Init(appName, appIcon string) error {
+// timeoutMillis converts a timeout to the value expected by the server:
+// 0 (never expires), -1 (server default) or a positive number of milliseconds.
+// Negative durations map to -1, positive durations are rounded up to whole
+// milliseconds and capped at the largest int32.
+func timeoutMillis(timeout time.Duration) int32 {
+	switch {
+	case timeout == ExpiresNever:
+		return 0
+	case timeout < 0:
+		return -1
+	}
+	ms := timeout / time.Millisecond
+	if timeout%time.Millisecond != 0 {
+		ms++
+	}
+	if ms > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(ms)
+}
+
+// callError wraps an error returned from a D-Bus call.
+func callError(err error) error {
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) {
+		switch dbusErr.Name {
+		case "org.freedesktop.DBus.Error.UnknownMethod":
+			return fmt.Errorf("%w: %v", ErrUnsupported, err)
+		case "org.freedesktop.DBus.Error.ServiceUnknown", "org.freedesktop.DBus.Error.NameHasNoOwner":
+			return fmt.Errorf("%w: %v", ErrNoServer, err)
+		}
+	}
+	return fmt.Errorf("notification: %w", err)
+}
+
+// SetAppName sets the application name which is sent with every notification.
+func SetAppName(appName string) {
+	mu.Lock()
 	AppName = appName
+	mu.Unlock()
+}
+
+// AppNameValue returns the application name.
+func AppNameValue() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return AppName
+}
+
+// SetAppIcon sets the icon which is used for notifications without an icon.
+func SetAppIcon(appIcon string) {
+	mu.Lock()
 	AppIcon = appIcon
-	var err error
-	busConn, err = dbus.SessionBus()
+	mu.Unlock()
+}
+
+// AppIconValue returns the application icon.
+func AppIconValue() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return AppIcon
+}
+
+// Extensions of image files which are used as icons.
+var iconExts = map[string]bool{
+	".png": true, ".svg": true, ".svgz": true, ".xpm": true, ".jpg": true, ".jpeg": true,
+}
+
+// resolveIcon returns the value which is sent to the server as app_icon.
+// Icon names from the icon theme (e.g. "dialog-warning"), URIs and absolute
+// paths are returned unchanged, relative paths are made absolute.
+func resolveIcon(icon string) string {
+	if icon == "" || strings.Contains(icon, "://") || filepath.IsAbs(icon) || isIconName(icon) {
+		return icon
+	}
+	abs, err := filepath.Abs(icon)
+	if err != nil {
+		return icon
+	}
+	return abs
+}
+
+// FileURI returns a file:// URI for a file path. A leading "~" is replaced
+// with the user's home directory and relative paths are made absolute.
+func FileURI(path string) (string, error) {
+	abs, err := absPath(path)
+	if err != nil {
+		return "", err
+	}
+	uri := url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	return uri.String(), nil
+}
+
+// absPath returns the absolute path with a leading "~" expanded.
+func absPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("notification: %w", err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("notification: %w", err)
+	}
+	return abs, nil
+}
+
+// isIconName reports whether icon is the name of an icon in the icon theme
+// rather than a file path or URI.
+func isIconName(icon string) bool {
+	return !strings.Contains(icon, "://") && !strings.ContainsRune(icon, filepath.Separator) &&
+		!iconExts[strings.ToLower(filepath.Ext(icon))]
+}
+
+// Init connects to the session bus, sets the appName and appIcon and
+// starts an event loop.
+func Init(appName, appIcon string, opts ...Option) error {
+	return InitContext(context.Background(), appName, appIcon, opts...)
+}
+
+// InitContext is like Init but the connection to the session bus and the
+// registration of the match rules are aborted when ctx is done. In this case
+// the context's error is returned and the package stays uninitialized.
+//
+// The package uses its own connection to the session bus. If it was
+// initialized before, the previous connection is closed.
+func InitContext(ctx context.Context, appName, appIcon string, opts ...Option) error {
+	cfg := newConfig(opts)
+	conn, err := sessionBus(ctx)
+	if err != nil && cfg.fallback != nil && ctx.Err() == nil {
+		SetAppName(appName)
+		SetAppIcon(appIcon)
+		conf = cfg
+		fallback = cfg.fallback
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("notification: Failed to connect to session bus: %w", err)
 	}
-	notifications = make(map[uint32]*Notification)
-	busObj = busConn.Object(busName, objPath)
-	err = addMatch("NotificationClosed")
+	portal := false
+	if cfg.portal {
+		err = serverAvailable(ctx, conn)
+		if ctx.Err() != nil {
+			conn.Close()
+			return fmt.Errorf("notification: %w", ctx.Err())
+		}
+		portal = err != nil
+	}
+	server := busName
+	if cfg.destination != "" {
+		server = cfg.destination
+	}
+	rules := []string{matchRule(server, objPath, "NotificationClosed"),
+		matchRule(server, objPath, "ActionInvoked")}
+	if portal {
+		server = portalBusName
+		rules = []string{matchRule(portalBusName, portalObjPath, "ActionInvoked")}
+	}
+	rules = append(rules, ownerMatchRule(server))
+	for _, rule := range rules {
+		err = addMatch(ctx, conn, rule)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("notification: %w", err)
+		}
+	}
+	owner, err := nameOwner(ctx, conn, server)
 	if err != nil {
-		return fmt.Errorf("notification: %w", err)
+		conn.Close()
+		return err
+	}
+	SetAppName(appName)
+	SetAppIcon(appIcon)
+	if busConn != nil {
+		busConn.Close()
+	}
+	busConn = conn
+	conf = cfg
+	fallback = nil
+	var entry string
+	if cfg.autoDesktopEntry {
+		entry = autoDesktopEntry(appName)
+	}
+	setAutoDesktopEntry(entry)
+	dispatch = newDispatcher(cfg)
+	mu.Lock()
+	serverName, serverOwner = server, owner
+	notifications = make(map[uint32]*Notification)
+	signalHandlers = make(map[string][]func(*dbus.Signal))
+	availabilityHandlers = nil
+	inhibitedHandlers = nil
+	mu.Unlock()
+	usePortal = portal
+	if usePortal {
+		busObj = busConn.Object(portalBusName, portalObjPath)
+	} else if cfg.destination != "" {
+		busObj = busConn.Object(cfg.destination, objPath)
+	} else {
+		busObj = busConn.Object(busName, objPath)
+	}
+	c := cfg.signals
+	if c == nil {
+		c = make(chan *dbus.Signal, sigBufferSize)
+		busConn.Signal(c)
+	}
+	syncRequests = make(chan chan struct{})
+	loopDone = make(chan struct{})
+	wake := make(chan struct{}, 1)
+	mu.Lock()
+	loopWake = wake
+	mu.Unlock()
+	go signalLoop(c, syncRequests, wake, loopDone)
+	activeQuirk = nil
+	if cfg.quirks {
+		activeQuirk = lookupQuirk(ctx)
+	}
+	return nil
+}
+
+// AddSignalHandler adds a function which handles the signal member
+// (e.g. "NotificationClosed" or a vendor-specific signal) emitted on the
+// object /org/freedesktop/Notifications. A match rule for the signal is
+// registered on the connection established with Init(); if that fails
+// the handler is not added.
+func AddSignalHandler(member string, handler func(*dbus.Signal)) error {
+	if busConn == nil {
+		return fmt.Errorf("notification: D-Bus not initialized")
+	}
+	matchMu.Lock()
+	defer matchMu.Unlock()
+	mu.Lock()
+	_, ok := signalHandlers[member]
+	mu.Unlock()
+	if !ok {
+		err := addMatch(context.Background(), busConn,
+			matchRule(busObj.Destination(), objPath, member))
+		if err != nil {
+			return callError(err)
+		}
+	}
+	mu.Lock()
+	signalHandlers[member] = append(signalHandlers[member], handler)
+	mu.Unlock()
+	return nil
+}
+
+// Sync waits until the signals which were received before the call have
+// been dispatched to their handlers (which may still be running).
+// It makes a round-trip to the bus and then waits until the event loop has
+// processed the signals which are queued at that time.
+// Because godbus delivers signals asynchronously, a signal which arrived
+// immediately before the reply of the round-trip might not be covered.
+// An error is returned if the event loop has stopped, e.g. because the
+// connection to the session bus was lost.
+func Sync(ctx context.Context) error {
+	if busConn == nil {
+		return fmt.Errorf("notification: D-Bus not initialized")
 	}
-	err = addMatch("ActionInvoked")
+	err := callContext(ctx, busConn.BusObject(), "org.freedesktop.DBus.Peer.Ping").Err
 	if err != nil {
-		return fmt.Errorf("notification: %w", err)
+		return callError(err)
+	}
+	done := make(chan struct{})
+	select {
+	case syncRequests <- done:
+	case <-loopDone:
+		return fmt.Errorf("notification: Event loop stopped")
+	case <-ctx.Done():
+		return fmt.Errorf("notification: %w", ctx.Err())
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("notification: %w", ctx.Err())
+	}
+}
+
+// BusObject returns the object which is used for calls to the notification
+// server or nil if Init() was not called. It can be used for calls which
+// are not provided by this package. Use at your own risk: the package's
+// handling of signals must not be disturbed.
+func BusObject() dbus.BusObject {
+	return busObj
+}
+
+// BusConn returns the connection to the session bus or nil if Init() was
+// not called. The same caveats as for BusObject() apply.
+func BusConn() *dbus.Conn {
+	return busConn
+}
+
+// signalLoop handles signals until c is closed, e.g. when the connection
+// to the session bus is lost, and then closes done. For a request from
+// Sync() it handles the signals which are queued in c and then closes
+// the channel of the request. When it is woken with wake it dispatches
+// the functions passed to post().
+func signalLoop(c <-chan *dbus.Signal, syncs <-chan chan struct{}, wake chan struct{}, done chan<- struct{}) {
+	defer func() {
+		mu.Lock()
+		var fs []func()
+		if loopWake == wake {
+			loopWake = nil
+			fs, posted = posted, nil
+		}
+		mu.Unlock()
+		for _, f := range fs {
+			go runHandler(f)
+		}
+		close(done)
+	}()
+	for {
+		select {
+		case sig, ok := <-c:
+			if !ok {
+				return
+			}
+			handleSignal(sig)
+		case <-wake:
+			mu.Lock()
+			var fs []func()
+			if loopWake == wake {
+				fs, posted = posted, nil
+			}
+			mu.Unlock()
+			for _, f := range fs {
+				dispatch(f)
+			}
+		case ch := <-syncs:
+			for n := len(c); n > 0; n-- {
+				sig, ok := <-c
+				if !ok {
+					close(ch)
+					return
+				}
+				handleSignal(sig)
+			}
+			close(ch)
+		}
+	}
+}
+
+// post executes a handler function for an event which does not come from
+// the event loop, e.g. an expired timer. The function is passed to the
+// event loop and dispatched like the handlers of signals, so post never
+// blocks, even if all workers of the pool are busy (see WithWorkerPool()).
+// If the event loop is not running, the function is executed in a new
+// goroutine.
+func post(f func()) {
+	mu.Lock()
+	wake := loopWake
+	if wake != nil {
+		posted = append(posted, f)
+	}
+	mu.Unlock()
+	if wake == nil {
+		go runHandler(f)
+		return
+	}
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// handleSignal dispatches a signal from the bus or the server to its
+// handlers. Other signals, e.g. from another service on a channel passed
+// to WithSignalChannel(), are ignored.
+func handleSignal(sig *dbus.Signal) {
+	if sig == nil {
+		return
+	}
+	if sig.Name == nameOwnerChanged {
+		if sig.Sender == "org.freedesktop.DBus" {
+			serverOwnerChanged(sig)
+		}
+		return
+	}
+	mu.Lock()
+	owner := serverOwner
+	mu.Unlock()
+	if owner == "" || sig.Sender != owner {
+		return
+	}
+	if sig.Path == objPath {
+		member := sig.Name[strings.LastIndex(sig.Name, ".")+1:]
+		mu.Lock()
+		handlers := signalHandlers[member]
+		mu.Unlock()
+		for _, handler := range handlers {
+			handler := handler
+			dispatch(func() { handler(sig) })
+		}
+	}
+	if len(sig.Body) < 2 {
+		return
 	}
-	c := make(chan *dbus.Signal, sigBufferSize)
-	busConn.Signal(c)
+	if sig.Name == portalInterface+".ActionInvoked" && sig.Path == portalObjPath {
+		portalActionInvoked(sig)
+		return
+	}
+	if sig.Path != objPath {
+		return
+	}
+	if sig.Name == propertiesChanged {
+		serverPropertiesChanged(sig)
+		return
+	}
+	id, ok := sig.Body[0].(uint32)
+	if !ok {
+		return
+	}
+	switch sig.Name {
+	case busInterface + ".NotificationClosed":
+		if reason, ok := sig.Body[1].(uint32); ok {
+			notificationClosedHandler(id, reason)
+		}
+	case busInterface + ".ActionInvoked":
+		if key, ok := sig.Body[1].(string); ok {
+			actionInvokedHandler(id, key)
+		}
+	}
+}
+
+// sessionBus returns a private connection to the session bus. A private
+// connection is used because the shared one cannot be closed: if ctx is
+// done first, the connection is closed as soon as it is established, so
+// the goroutine which connects does not stay around.
+func sessionBus(ctx context.Context) (*dbus.Conn, error) {
+	type result struct {
+		conn *dbus.Conn
+		err  error
+	}
+	ch := make(chan result)
 	go func() {
-		for {
-			sig := <-c
-			if strings.HasSuffix(sig.Name, ".NotificationClosed") {
-				notificationClosedHandler(sig.Body[0].(uint32), sig.Body[1].(uint32))
-			} else if strings.HasSuffix(sig.Name, ".ActionInvoked") {
-				actionInvokedHandler(sig.Body[0].(uint32), sig.Body[1].(string))
+		conn, err := dbus.SessionBusPrivate()
+		if err == nil {
+			err = conn.Auth(nil)
+			if err == nil {
+				err = conn.Hello()
+			}
+			if err != nil {
+				conn.Close()
+				conn = nil
+			}
+		}
+		select {
+		case ch <- result{conn, err}:
+		case <-ctx.Done():
+			if conn != nil {
+				conn.Close()
 			}
 		}
 	}()
-	return nil
+	select {
+	case r := <-ch:
+		return r.conn, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// callContext calls a method and waits for its reply or until ctx is done.
+func callContext(ctx context.Context, obj dbus.BusObject, method string, args ...interface{}) *dbus.Call {
+	call := obj.Go(method, 0, make(chan *dbus.Call, 1), args...)
+	select {
+	case call = <-call.Done:
+		return call
+	case <-ctx.Done():
+		return &dbus.Call{Err: ctx.Err()}
+	}
+}
+
+// matchRule returns a match rule for signals from the sender, which may
+// be a well-known name; the bus then matches its current owner.
+func matchRule(sender, path, member string) string {
+	return fmt.Sprintf("type='signal',sender='%s',path='%s',member='%s'", sender, path, member)
+}
+
+// ownerMatchRule returns a match rule for the changes of the owner of name.
+func ownerMatchRule(name string) string {
+	return fmt.Sprintf("type='signal',sender='org.freedesktop.DBus',"+
+		"member='NameOwnerChanged',arg0='%s'", name)
 }
 
-func addMatch(member string) error {
-	call := busConn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0,
-		fmt.Sprintf("type='signal',path='%s',member='%s'", objPath, member))
-	return call.Err
+// nameOwner returns the unique name of the owner of name or an empty
+// string if it has none.
+func nameOwner(ctx context.Context, conn *dbus.Conn, name string) (string, error) {
+	var owner string
+	err := callContext(ctx, conn.BusObject(), "org.freedesktop.DBus.GetNameOwner", name).Store(&owner)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("notification: %w", ctx.Err())
+		}
+		var dbusErr dbus.Error
+		if errors.As(err, &dbusErr) && dbusErr.Name == "org.freedesktop.DBus.Error.NameHasNoOwner" {
+			return "", nil
+		}
+		return "", callError(err)
+	}
+	return owner, nil
+}
+
+func addMatch(ctx context.Context, conn *dbus.Conn, rule string) error {
+	return callContext(ctx, conn.BusObject(), "org.freedesktop.DBus.AddMatch", rule).Err
 }
 
 func actionInvokedHandler(id uint32, key string) {
+	var handler func()
+	var lifecycle func(LifecycleEvent)
+	var waiters []chan<- actionResult
+	mu.Lock()
 	noti, ok := notifications[id]
 	if ok {
-		action, ok := noti.actions[key]
-		if ok {
-			go action.handler()
+		for _, action := range noti.actions {
+			if key == noti.actionKey(action) {
+				handler = action.Handler
+				key = action.Key
+				waiters = append(waiters, actionWaiters[noti]...)
+				break
+			}
 		}
+		lifecycle = noti.lifecycleHandler
+	}
+	mu.Unlock()
+	if !ok {
+		droppedSignal("ActionInvoked", id)
+		return
+	}
+	if handler != nil {
+		dispatch(handler)
 	}
+	notifyActionWaiters(waiters, noti, key)
+	lifecycleEvent(lifecycle, LifecycleEvent{Phase: LifecycleActioned, ID: id, ActionKey: key})
 }
 
 func notificationClosedHandler(id, reason uint32) {
+	var handler func(uint32)
+	var lifecycle func(LifecycleEvent)
+	mu.Lock()
 	noti, ok := notifications[id]
 	if ok {
-		delete(notifications, id)
-		if noti.closedHandler != nil {
-			go noti.closedHandler(reason)
+		noti.untrack(id)
+		handler = noti.closedHandler
+		lifecycle = noti.lifecycleHandler
+	}
+	mu.Unlock()
+	if !ok {
+		droppedSignal("NotificationClosed", id)
+		return
+	}
+	if handler != nil {
+		dispatch(func() { handler(reason) })
+	}
+	lifecycleEvent(lifecycle, LifecycleEvent{Phase: LifecycleClosed, ID: id, Reason: Reason(reason)})
+}
+
+// droppedSignals counts signals for unknown notifications; accessed atomically.
+var droppedSignals uint64
+
+func droppedSignal(name string, id uint32) {
+	atomic.AddUint64(&droppedSignals, 1)
+	logf("Dropped %s signal for unknown notification %d", name, id)
+}
+
+// DroppedSignals returns the number of NotificationClosed and ActionInvoked
+// signals which were ignored because they belong to an unknown notification,
+// e.g. one which was already closed or one sent by another application.
+// The server broadcasts these signals to all clients and the specification
+// does not tell which client sent a notification, so signals for the
+// notifications of other applications are received as well. Only signals
+// from the notification server are received, though.
+// A warning is logged for each dropped signal (see WithLogger()).
+func DroppedSignals() uint64 {
+	return atomic.LoadUint64(&droppedSignals)
+}
+
+// Available checks whether a notification server is available, i.e. whether
+// the name org.freedesktop.Notifications has an owner on the session bus or
+// can be activated by the bus. No notification will be shown.
+// If Init() was not called the shared connection to the session bus is used.
+func Available() error {
+	conn := busConn
+	if conn == nil {
+		var err error
+		conn, err = dbus.SessionBus()
+		if err != nil {
+			return fmt.Errorf("notification: Failed to connect to session bus: %w", err)
 		}
 	}
+	return serverAvailable(context.Background(), conn)
+}
+
+func serverAvailable(ctx context.Context, conn *dbus.Conn) error {
+	running, err := serverRunning(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if running {
+		return nil
+	}
+	var names []string
+	err = callContext(ctx, conn.BusObject(), "org.freedesktop.DBus.ListActivatableNames").Store(&names)
+	if err != nil {
+		return callError(err)
+	}
+	for _, name := range names {
+		if name == busName {
+			return nil
+		}
+	}
+	return ErrNoServer
+}
+
+// serverRunning reports whether org.freedesktop.Notifications has an owner.
+func serverRunning(ctx context.Context, conn *dbus.Conn) (bool, error) {
+	var hasOwner bool
+	err := callContext(ctx, conn.BusObject(), "org.freedesktop.DBus.NameHasOwner", busName).Store(&hasOwner)
+	if err != nil {
+		return false, callError(err)
+	}
+	return hasOwner, nil
+}
+
+// IsAvailable reports whether a notification server is available
+// (see Available()).
+func IsAvailable() bool {
+	return Available() == nil
 }
 
 // GetCapabilities calls org.freedesktop.Notifications.GetCapabilities.
-func GetCapabilities() (result []string, err error) {
+func GetCapabilities() ([]string, error) {
+	return GetCapabilitiesContext(context.Background())
+}
+
+// GetCapabilitiesContext is like GetCapabilities but returns the context's
+// error if ctx is done before the server replies.
+func GetCapabilitiesContext(ctx context.Context) (result []string, err error) {
+	if busObj == nil && fallback != nil {
+		return fallback.GetCapabilities()
+	}
+	if usePortal {
+		return nil, ErrUnsupported
+	}
 	if busObj == nil {
 		return nil, fmt.Errorf("notification: D-Bus not initialized")
 	}
-	err = busObj.Call(busInterface+".GetCapabilities", 0).Store(&result)
+	err = callContext(ctx, busObj, busInterface+".GetCapabilities").Store(&result)
 	if err != nil {
-		err = fmt.Errorf("notification: %w", err)
+		err = callError(err)
 	}
 	return
 }
 
+// GetActiveNotificationIDs returns the sorted IDs of the notifications
+// which were sent with Notify() and have not been closed yet.
+// The specification does not define a method to enumerate the notifications
+// shown by the server and there is no common extension for it either,
+// so only the locally tracked notifications can be reported.
+func GetActiveNotificationIDs() ([]uint32, error) {
+	if busObj == nil {
+		return nil, fmt.Errorf("notification: D-Bus not initialized")
+	}
+	mu.Lock()
+	ids := make([]uint32, 0, len(notifications))
+	for id := range notifications {
+		ids = append(ids, id)
+	}
+	mu.Unlock()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// ActiveBySummary returns the IDs of the active notifications (see
+// GetActiveNotificationIDs()) grouped by their summaries. The result is a
+// snapshot which can be used to close notifications with CloseNotificationByID().
+func ActiveBySummary() map[string][]uint32 {
+	result := make(map[string][]uint32)
+	mu.Lock()
+	for id, noti := range notifications {
+		result[noti.summary] = append(result[noti.summary], id)
+	}
+	mu.Unlock()
+	for _, ids := range result {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	return result
+}
+
 // ServerInfo represents server information.
 type ServerInfo struct {
 	Name        string
@@ -148,67 +861,275 @@ type ServerInfo struct {
 	SpecVersion string
 }
 
+// ParseVersion parses the server's version into its numeric components,
+// e.g. "1.9.2" into [1 9 2], for comparing versions of the same server
+// (see Name). A leading "v" and anything after the first space, "-" or "+"
+// (e.g. "1.9.2 (2023-04-20)" or "3.38.1-ubuntu1") are ignored.
+// An error is returned if the version is not numeric, e.g. a git hash.
+func (si *ServerInfo) ParseVersion() ([]int, error) {
+	version := strings.TrimPrefix(si.Version, "v")
+	if i := strings.IndexAny(version, " -+"); i >= 0 {
+		version = version[:i]
+	}
+	parts := strings.Split(version, ".")
+	result := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("notification: Invalid version: %q", si.Version)
+		}
+		result[i] = n
+	}
+	return result, nil
+}
+
 // GetServerInformation calls org.freedesktop.Notifications.GetServerInformation.
 func GetServerInformation() (*ServerInfo, error) {
+	return GetServerInformationContext(context.Background())
+}
+
+// GetServerInformationContext is like GetServerInformation but returns the
+// context's error if ctx is done before the server replies.
+func GetServerInformationContext(ctx context.Context) (*ServerInfo, error) {
+	if busObj == nil && fallback != nil {
+		return fallback.GetServerInformation()
+	}
+	if usePortal {
+		return nil, ErrUnsupported
+	}
 	if busObj == nil {
 		return nil, fmt.Errorf("notification: D-Bus not initialized")
 	}
-	call := busObj.Call(busInterface+".GetServerInformation", 0)
+	call := callContext(ctx, busObj, busInterface+".GetServerInformation")
 	if call.Err != nil {
-		return nil, fmt.Errorf("notification: %w", call.Err)
+		return nil, callError(call.Err)
+	}
+	return parseServerInfo(call.Body)
+}
+
+// parseServerInfo creates a ServerInfo from the reply of GetServerInformation.
+func parseServerInfo(body []interface{}) (*ServerInfo, error) {
+	if len(body) < 4 {
+		return nil, fmt.Errorf("notification: Invalid server information: %d values instead of 4",
+			len(body))
+	}
+	var values [4]string
+	for i := range values {
+		s, ok := body[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("notification: Invalid server information: value %d is %T",
+				i, body[i])
+		}
+		values[i] = s
 	}
-	serverInfo := ServerInfo{call.Body[0].(string), call.Body[1].(string),
-		call.Body[2].(string), call.Body[3].(string)}
-	return &serverInfo, nil
+	return &ServerInfo{values[0], values[1], values[2], values[3]}, nil
 }
 
 // Notify sends a notification.
 func Notify(noti *Notification) error {
-	var icon string
+	return notify(context.Background(), noti, noti.icon)
+}
+
+// Send sends the notification with Notify() and returns the ID
+// assigned by the server.
+func (noti *Notification) Send() (uint32, error) {
+	err := Notify(noti)
+	if err != nil {
+		return 0, err
+	}
+	return noti.id, nil
+}
+
+// NotifyWithCancel sends the notification with Notify() and closes it
+// when ctx is done. The goroutine which waits for ctx ends when the
+// notification is closed before. The ID is read when ctx is done, so
+// the notification is closed even if the server has replaced it with a
+// new ID in the meantime.
+func NotifyWithCancel(ctx context.Context, noti *Notification) error {
+	err := Notify(noti)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	done := noti.done
+	mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			err := CloseNotification(noti)
+			if err != nil {
+				reportError(err)
+			}
+		case <-done:
+		}
+	}()
+	return nil
+}
+
+// NotifyAll sends the notifications in order and returns an error for each
+// of them (nil if it was sent successfully).
+func NotifyAll(notis []*Notification) []error {
+	errs := make([]error, len(notis))
+	for i, noti := range notis {
+		errs[i] = Notify(noti)
+	}
+	return errs
+}
+
+// TestNotification sends a short-lived notification with the summary
+// "Notifications are working", e.g. for a button in the settings of an
+// application. It returns an error if the notification could not be sent
+// before ctx is done. If the package uses the terminal fallback (see
+// WithTerminalFallback()) ErrNoServer is returned.
+func TestNotification(ctx context.Context) error {
+	if busObj == nil && fallback != nil {
+		return ErrNoServer
+	}
+	noti := New("Notifications are working", "")
+	noti.SetTimeout(3 * time.Second)
+	noti.SetTransient(true)
+	return notify(ctx, noti, noti.icon)
+}
+
+// NotifyWithIcon sends a notification with the given icon instead of
+// the notification's own icon. The notification itself is not changed.
+func (noti *Notification) NotifyWithIcon(icon string) error {
+	return notify(context.Background(), noti, icon)
+}
+
+func notify(ctx context.Context, noti *Notification, icon string) error {
+	if busObj == nil && fallback != nil {
+		return fallback.Notify(noti)
+	}
 	if busObj == nil {
 		return fmt.Errorf("notification: D-Bus not initialized")
 	}
-	if noti.icon == "" {
-		icon = AppIcon
-	} else {
-		icon = noti.icon
+	if err := noti.checkActions(); err != nil {
+		return err
+	}
+	if icon == "" && !noti.noIcon {
+		icon = AppIconValue()
+	}
+	if usePortal {
+		return portalNotify(ctx, noti, icon)
+	}
+	icon = resolveIcon(icon)
+	timeout := noti.timeout
+	if noti.urgency == UrgencyCritical && timeout > 0 {
+		if conf.criticalNoExpire {
+			timeout = ExpiresNever
+		} else {
+			logf("Timeout %v of critical notification %q may be ignored by the server",
+				timeout, noti.summary)
+		}
+	}
+	hints := noti.sendHints()
+	if activeQuirk != nil {
+		activeQuirk.apply(hints, &timeout)
 	}
-	if icon != "" {
-		icon, _ = filepath.Abs(icon)
+	appName := AppNameValue()
+	replacesID := noti.id
+	if replacesID == 0 && conf.coalesce > 0 {
+		replacesID = coalescedID(appName, noti)
 	}
-	noti.hints["urgency"] = dbus.MakeVariant(noti.urgency)
-	err := busObj.Call(busInterface+".Notify", 0, AppName, noti.id, icon, noti.summary, noti.body,
-		noti.actionlist(), noti.hints, int32(noti.timeout.Seconds()*1000)).Store(&noti.id)
+	args := []interface{}{appName, replacesID, icon, noti.summary, noti.body,
+		noti.actionlist(), hints, timeoutMillis(timeout)}
+	if noti.noReply {
+		err := busObj.Call(busInterface+".Notify", dbus.FlagNoReplyExpected, args...).Err
+		if err != nil {
+			return callError(err)
+		}
+		return nil
+	}
+	oldID := noti.id
+	err := callContext(ctx, busObj, busInterface+".Notify", args...).Store(&noti.id)
 	if err != nil {
-		err = fmt.Errorf("notification: %w", err)
+		err = callError(err)
 	} else {
-		notifications[noti.id] = noti
+		mu.Lock()
+		noti.track(oldID)
+		if conf.coalesce > 0 {
+			recordCoalesced(appName, noti)
+		}
+		noti.startExpireTimer(noti.timeout)
+		lifecycle := noti.lifecycleHandler
+		mu.Unlock()
+		postLifecycleEvent(lifecycle, LifecycleEvent{Phase: LifecycleSent, ID: noti.id})
 	}
 	return err
 }
 
 // CloseNotification closes a notification.
 func CloseNotification(noti *Notification) error {
-	return busObj.Call(busInterface+".CloseNotification", 0, noti.id).Err
+	if busObj == nil && fallback != nil {
+		return fallback.CloseNotification(noti)
+	}
+	mu.Lock()
+	id := noti.id
+	mu.Unlock()
+	return CloseNotificationByID(id)
+}
+
+// CloseNotificationByID closes the notification with the given ID.
+func CloseNotificationByID(id uint32) error {
+	if busObj == nil {
+		return fmt.Errorf("notification: D-Bus not initialized")
+	}
+	if usePortal {
+		return portalClose(id)
+	}
+	err := busObj.Call(busInterface+".CloseNotification", 0, id).Err
+	if err != nil {
+		return callError(err)
+	}
+	mu.Lock()
+	if noti, ok := notifications[id]; ok {
+		noti.stopExpireTimer()
+	}
+	mu.Unlock()
+	return nil
 }
 
-type action struct {
-	name    string
-	handler func()
+// Action represents an action of a notification.
+//
+// If the hint "action-icons" is set to true, IconName is sent to the server
+// as the action's key, so the server can show it as an icon from the icon
+// theme; the server then also sends it back when the action is invoked.
+// Actions without an IconName are sent with their Key.
+type Action struct {
+	Key      string // the key sent back by the server when the action is invoked
+	Name     string // the label shown to the user
+	IconName string // the name of an icon in the icon theme (optional)
+	Handler  func() // the function which handles the action (optional)
 }
 
 // Notification represents a desktop notification.
 // A notification can be modified and updated/shown again on the screen with Notify().
+//
+// When a notification is sent it is tracked by its ID, so its handlers are
+// called for signals with this ID. When it is closed it is no longer
+// tracked, but its handlers are kept: if it is sent again the server assigns
+// a new ID and the handlers are called only for signals with the new ID.
 type Notification struct {
 	id            uint32
 	icon          string
+	noIcon        bool
+	noReply       bool
 	summary       string
 	body          string
 	urgency       Urgency
 	timeout       time.Duration
-	actions       map[string]action
+	actions       []Action
 	hints         map[string]dbus.Variant
 	closedHandler func(uint32)
+	expireHandler func()
+	expireTimer   *time.Timer // guarded by mu
+
+	lifecycleHandler func(LifecycleEvent) // guarded by mu
+	done             chan struct{}        // closed when untracked; guarded by mu
 }
 
 // New creates a new Notification.
@@ -219,16 +1140,77 @@ func New(summary, body string) *Notification {
 	noti.body = body
 	noti.urgency = UrgencyNormal
 	noti.timeout = ExpiresDefault
-	noti.hints = make(map[string]dbus.Variant, 1)
 	return &noti
 }
 
+// Clone returns a copy of the notification which has not been sent yet,
+// so Notify() will show it as a new notification. Hints and actions are
+// copied, handler functions are shared.
+func (noti *Notification) Clone() *Notification {
+	mu.Lock()
+	clone := *noti
+	mu.Unlock()
+	clone.id = 0
+	clone.expireTimer = nil
+	clone.done = nil
+	clone.ResetHintsFrom(noti)
+	clone.actions = noti.Actions()
+	return &clone
+}
+
+// ResetHintsFrom replaces the notification's hints with a copy of the
+// hints of other, e.g. a template, so repeated sends carry exactly the
+// same hints.
+func (noti *Notification) ResetHintsFrom(other *Notification) {
+	noti.hints = make(map[string]dbus.Variant, len(other.hints))
+	for key, value := range other.hints {
+		noti.hints[key] = value
+	}
+}
+
+// ForceNew resets the notification's ID, so the next call of Notify()
+// shows a new notification instead of replacing the one sent before.
+// Handlers of the previous notification will no longer be called.
+func (noti *Notification) ForceNew() {
+	mu.Lock()
+	if notifications[noti.id] == noti {
+		noti.untrack(noti.id)
+	}
+	mu.Unlock()
+	noti.id = 0
+}
+
+// IsActive reports whether the notification was sent with Notify() and
+// has not been closed yet.
+func (noti *Notification) IsActive() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return noti.id != 0 && notifications[noti.id] == noti
+}
+
 // SetIcon sets the notification's icon.
-// If icon is an empty string AppIcon will be used.
+// This is either a file path, a URI or the name of an icon in the icon theme.
+// If icon is an empty string AppIcon will be used (see SuppressIcon()).
 func (noti *Notification) SetIcon(icon string) {
 	noti.icon = icon
 }
 
+// SuppressIcon sends the notification without an icon if it has none
+// instead of using AppIcon.
+func (noti *Notification) SuppressIcon(suppress bool) {
+	noti.noIcon = suppress
+}
+
+// SetNoReply sends the notification without waiting for the server to
+// assign an ID, which saves a round-trip, e.g. for notifications which are
+// sent at a high rate. The notification's ID is not changed, so it is not
+// tracked: its action, closed and expire handlers are never called, it
+// cannot be closed or replaced and errors from the server are not reported.
+// It is ignored by the notification portal.
+func (noti *Notification) SetNoReply(noReply bool) {
+	noti.noReply = noReply
+}
+
 // SetSummary sets the notification's summary.
 // This is a single line overview of the notification.
 func (noti *Notification) SetSummary(summary string) {
@@ -241,8 +1223,23 @@ func (noti *Notification) SetBody(body string) {
 	noti.body = body
 }
 
+// UpdateSummary sets the notification's summary and sends it again with
+// Notify(), so a notification which is still shown is updated in place.
+func (noti *Notification) UpdateSummary(summary string) error {
+	noti.summary = summary
+	return Notify(noti)
+}
+
+// UpdateBody sets the notification's body and sends it again with
+// Notify(), so a notification which is still shown is updated in place.
+func (noti *Notification) UpdateBody(body string) error {
+	noti.body = body
+	return Notify(noti)
+}
+
 // SetUrgency sets the notification's urgency level.
-// This is one of the Urgency* constants.
+// This is one of the Urgency* constants; with UrgencyUnset no urgency hint
+// is sent and the server uses its default.
 func (noti *Notification) SetUrgency(urgency Urgency) {
 	noti.urgency = urgency
 }
@@ -254,14 +1251,23 @@ func (noti *Notification) SetTimeout(timeout time.Duration) {
 	noti.timeout = timeout
 }
 
+// SetTimeoutMillis sets the expiration timeout in milliseconds as defined
+// by the specification: 0 means the notification never expires, -1 means
+// the server's default is used.
+func (noti *Notification) SetTimeoutMillis(ms int32) {
+	noti.timeout = time.Duration(ms) * time.Millisecond
+}
+
 // AddHint adds a hint to the notification.
-// A hint with the key "urgency" will be ignored; use SetUrgency().
+// A hint with the key "urgency" is not added because the package manages
+// it; use SetUrgency(). This applies to the SetHint* methods as well.
+// Setting value to nil will remove the hint.
 // See the specification for more details.
 func (noti *Notification) AddHint(key string, value interface{}) {
 	if value == nil {
 		delete(noti.hints, key)
 	} else {
-		noti.hints[key] = dbus.MakeVariant(value)
+		noti.setHint(key, value)
 	}
 }
 
@@ -273,24 +1279,207 @@ func (noti *Notification) SetClosedHandler(handler func(uint32)) {
 	noti.closedHandler = handler
 }
 
+// track adds the notification to the tracked notifications after it was
+// sent. oldID is the ID before it was sent; the server returns a new ID if
+// it does not know the old one. mu must be held.
+func (noti *Notification) track(oldID uint32) {
+	if notifications[oldID] == noti {
+		if oldID != noti.id {
+			delete(notifications, oldID)
+		}
+	} else {
+		noti.done = make(chan struct{})
+	}
+	if prev, ok := notifications[noti.id]; ok && prev != noti {
+		// prev was replaced, e.g. by a coalesced notification
+		prev.untrack(noti.id)
+	}
+	notifications[noti.id] = noti
+}
+
+// untrack removes the notification with the given ID from the tracked
+// notifications when it was closed. mu must be held.
+func (noti *Notification) untrack(id uint32) {
+	delete(notifications, id)
+	noti.stopExpireTimer()
+	if noti.done != nil {
+		close(noti.done)
+		noti.done = nil
+	}
+}
+
+// SetExpireHandler sets a function which is called when the notification's
+// timeout has elapsed and it has not been closed before. The timer runs in
+// this process and is independent of the server, so it works even with
+// servers which ignore timeouts; it is only started for positive timeouts.
+// Closing the notification cancels the timer.
+// Setting handler to nil will remove the function.
+func (noti *Notification) SetExpireHandler(handler func()) {
+	noti.expireHandler = handler
+}
+
+// startExpireTimer starts the timer for the expire handler.
+// mu must be held.
+func (noti *Notification) startExpireTimer(timeout time.Duration) {
+	noti.stopExpireTimer()
+	if noti.expireHandler == nil || timeout <= 0 {
+		return
+	}
+	id := noti.id
+	var timer *time.Timer
+	timer = time.AfterFunc(timeout, func() {
+		mu.Lock()
+		ok := noti.expireTimer == timer && notifications[id] == noti
+		if ok {
+			noti.expireTimer = nil
+		}
+		handler := noti.expireHandler
+		mu.Unlock()
+		if ok && handler != nil {
+			post(handler)
+		}
+	})
+	noti.expireTimer = timer
+}
+
+// stopExpireTimer cancels the timer for the expire handler.
+// mu must be held.
+func (noti *Notification) stopExpireTimer() {
+	if noti.expireTimer != nil {
+		noti.expireTimer.Stop()
+		noti.expireTimer = nil
+	}
+}
+
 // AddActionHandler adds an action and a function to handle an
 // org.freedesktop.Notifications.ActionInvoked signal with the specified key.
+// Actions are sent to the server in the order they were added; adding an
+// action with an existing key replaces it in place.
 // Setting handler to nil will remove the function.
+// AddActionHandler panics if key is empty.
 func (noti *Notification) AddActionHandler(key, name string, handler func()) {
+	if key == "" {
+		panic("notification: Empty action key")
+	}
+	i := noti.actionIndex(key)
 	if handler == nil {
-		delete(noti.actions, key)
+		if i >= 0 {
+			noti.actions = append(noti.actions[:i], noti.actions[i+1:]...)
+		}
+	} else if i >= 0 {
+		noti.actions[i] = Action{Key: key, Name: name, IconName: noti.actions[i].IconName, Handler: handler}
 	} else {
-		if noti.actions == nil {
-			noti.actions = make(map[string]action, 1)
+		noti.actions = append(noti.actions, Action{Key: key, Name: name, Handler: handler})
+	}
+}
+
+// SetActions replaces all actions of the notification.
+// The actions are sent to the server in the given order. Their keys must be
+// unique and every action except the one with the key "default" (which is
+// invoked by clicking the notification) must have a name; otherwise
+// Notify() returns an error.
+// SetActions panics if an action has an empty key.
+func (noti *Notification) SetActions(actions []Action) {
+	for _, action := range actions {
+		if action.Key == "" {
+			panic("notification: Empty action key")
+		}
+	}
+	noti.actions = make([]Action, len(actions))
+	copy(noti.actions, actions)
+}
+
+// Actions returns a copy of the notification's actions in the order
+// in which they will be sent to the server.
+func (noti *Notification) Actions() []Action {
+	actions := make([]Action, len(noti.actions))
+	copy(actions, noti.actions)
+	return actions
+}
+
+func (noti *Notification) actionIndex(key string) int {
+	for i, action := range noti.actions {
+		if action.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkActions checks that the keys sent to the server are unique and
+// that every action except "default" has a name.
+func (noti *Notification) checkActions() error {
+	keys := make(map[string]bool, len(noti.actions))
+	for _, action := range noti.actions {
+		key := noti.actionKey(action)
+		if keys[key] {
+			return fmt.Errorf("notification: Duplicate action key: %q", key)
+		}
+		keys[key] = true
+		if action.Name == "" && action.Key != "default" {
+			return fmt.Errorf("notification: Action without name: %q", action.Key)
+		}
+	}
+	return nil
+}
+
+// actionKey returns the key which is sent to the server for an action.
+func (noti *Notification) actionKey(action Action) string {
+	if action.IconName == "" {
+		return action.Key
+	}
+	icons, _ := noti.hints[HintActionIcons].Value().(bool)
+	if icons {
+		return action.IconName
+	}
+	return action.Key
+}
+
+// ensureHints allocates the hints map, so a Notification which was not
+// created with New() can be used as well.
+func (noti *Notification) ensureHints() {
+	if noti.hints == nil {
+		noti.hints = make(map[string]dbus.Variant, 1)
+	}
+}
+
+// setHint adds a hint unless its key is managed by the package.
+func (noti *Notification) setHint(key string, value interface{}) {
+	if key == HintUrgency {
+		return
+	}
+	noti.ensureHints()
+	noti.hints[key] = dbus.MakeVariant(value)
+}
+
+// sendHints returns the hints which are sent to the server, i.e. the
+// notification's hints together with the urgency and the defaults.
+func (noti *Notification) sendHints() map[string]dbus.Variant {
+	hints := make(map[string]dbus.Variant, len(noti.hints)+2)
+	for key, value := range noti.hints {
+		hints[key] = value
+	}
+	if noti.urgency != UrgencyUnset {
+		hints["urgency"] = dbus.MakeVariant(noti.urgency)
+	}
+	mu.Lock()
+	defaults := map[string]string{
+		HintCategory:     defaultCategory,
+		HintDesktopEntry: defaultDesktopEntry,
+	}
+	mu.Unlock()
+	for key, value := range defaults {
+		if _, ok := hints[key]; !ok && value != "" {
+			hints[key] = dbus.MakeVariant(value)
 		}
-		noti.actions[key] = action{name, handler}
 	}
+	return hints
 }
 
 func (noti *Notification) actionlist() []string {
 	list := make([]string, 0, 2*len(noti.actions))
-	for key, action := range noti.actions {
-		list = append(list, key, action.name)
+	for _, action := range noti.actions {
+		list = append(list, noti.actionKey(action), action.Name)
 	}
 	return list
 }
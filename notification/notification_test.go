@@ -0,0 +1,563 @@
+package notification
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus"
+)
+
+func TestResolveIcon(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		icon string
+		want string
+	}{
+		{"", ""},
+		{"dialog-warning", "dialog-warning"},
+		{"org.example.App", "org.example.App"},
+		{"/usr/share/icons/app.png", "/usr/share/icons/app.png"},
+		{"file:///usr/share/icons/app.png", "file:///usr/share/icons/app.png"},
+		{"icons/app.png", filepath.Join(wd, "icons/app.png")},
+		{"app.png", filepath.Join(wd, "app.png")},
+		{"app.SVG", filepath.Join(wd, "app.SVG")},
+	}
+	for _, tt := range tests {
+		if got := resolveIcon(tt.icon); got != tt.want {
+			t.Errorf("resolveIcon(%q) = %q, want %q", tt.icon, got, tt.want)
+		}
+	}
+}
+
+func TestFileURI(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/tmp/file.txt", "file:///tmp/file.txt"},
+		{"/tmp/a b.txt", "file:///tmp/a%20b.txt"},
+		{"/tmp/ü.png", "file:///tmp/%C3%BC.png"},
+		{"/tmp/日本/画像.png", "file:///tmp/%E6%97%A5%E6%9C%AC/%E7%94%BB%E5%83%8F.png"},
+		{"/tmp/a#1.png", "file:///tmp/a%231.png"},
+		{"/tmp/a?b%c.png", "file:///tmp/a%3Fb%25c.png"},
+		{"file.txt", "file://" + filepath.Join(wd, "file.txt")},
+		{"~/file.txt", "file://" + filepath.Join(home, "file.txt")},
+	}
+	for _, tt := range tests {
+		got, err := FileURI(tt.path)
+		if err != nil {
+			t.Errorf("FileURI(%q): %v", tt.path, err)
+		} else if got != tt.want {
+			t.Errorf("FileURI(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCallError(t *testing.T) {
+	other := errors.New("connection closed")
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownMethod"}, ErrUnsupported},
+		{dbus.Error{Name: "org.freedesktop.DBus.Error.ServiceUnknown"}, ErrNoServer},
+		{dbus.Error{Name: "org.freedesktop.DBus.Error.NameHasNoOwner"}, ErrNoServer},
+		{other, other},
+	}
+	for _, tt := range tests {
+		got := callError(tt.err)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("callError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+	err := callError(dbus.Error{Name: "org.freedesktop.DBus.Error.NoReply"})
+	if errors.Is(err, ErrUnsupported) || errors.Is(err, ErrNoServer) {
+		t.Errorf("callError(NoReply) = %v, want a generic error", err)
+	}
+	var dbusErr dbus.Error
+	if !errors.As(err, &dbusErr) || dbusErr.Name != "org.freedesktop.DBus.Error.NoReply" {
+		t.Errorf("callError(NoReply) = %v, does not wrap the dbus.Error", err)
+	}
+}
+
+func TestSignalLoopStopsWhenChannelClosed(t *testing.T) {
+	c := make(chan *dbus.Signal)
+	stopped := make(chan struct{})
+	go signalLoop(c, make(chan chan struct{}), make(chan struct{}, 1), stopped)
+	c <- nil
+	close(c)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("signalLoop did not return after the channel was closed")
+	}
+}
+
+func TestParseServerInfo(t *testing.T) {
+	tests := []struct {
+		body []interface{}
+		ok   bool
+	}{
+		{[]interface{}{"dunst", "knopwob", "1.9.2", "1.2"}, true},
+		{[]interface{}{"dunst", "knopwob", "1.9.2", "1.2", "extra"}, true},
+		{[]interface{}{"dunst", "knopwob", "1.9.2"}, false},
+		{nil, false},
+		{[]interface{}{"dunst", "knopwob", uint32(1), "1.2"}, false},
+	}
+	for _, tt := range tests {
+		info, err := parseServerInfo(tt.body)
+		if tt.ok && (err != nil || info.Name != "dunst" || info.SpecVersion != "1.2") {
+			t.Errorf("parseServerInfo(%v) = %v, %v", tt.body, info, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("parseServerInfo(%v) = %v, want an error", tt.body, info)
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    []int
+	}{
+		{"1.9.2", []int{1, 9, 2}},
+		{"v5", []int{5}},
+		{"1.9.2 (2023-04-20)", []int{1, 9, 2}},
+		{"3.38.1-ubuntu1", []int{3, 38, 1}},
+		{"0.8+git", []int{0, 8}},
+		{"", nil},
+		{"1.", nil},
+		{".1", nil},
+		{"1..2", nil},
+		{"a1b2c3d", nil},
+		{"1.x", nil},
+		{"-1", nil},
+	}
+	for _, tt := range tests {
+		si := ServerInfo{Version: tt.version}
+		got, err := si.ParseVersion()
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("ParseVersion(%q) = %v, want an error", tt.version, got)
+			}
+		} else if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseVersion(%q) = %v, %v, want %v", tt.version, got, err, tt.want)
+		}
+	}
+}
+
+func TestTimeoutMillis(t *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		want    int32
+	}{
+		{ExpiresNever, 0},
+		{ExpiresDefault, -1},
+		{-time.Nanosecond, -1},
+		{-5 * time.Second, -1},
+		{time.Duration(math.MinInt64), -1},
+		{time.Nanosecond, 1},
+		{999 * time.Microsecond, 1},
+		{time.Millisecond, 1},
+		{1500 * time.Microsecond, 2},
+		{5 * time.Second, 5000},
+		{math.MaxInt32 * time.Millisecond, math.MaxInt32},
+		{(math.MaxInt32 + 1) * time.Millisecond, math.MaxInt32},
+		{time.Duration(math.MaxInt64), math.MaxInt32},
+	}
+	for _, tt := range tests {
+		if got := timeoutMillis(tt.timeout); got != tt.want {
+			t.Errorf("timeoutMillis(%v) = %d, want %d", tt.timeout, got, tt.want)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	noti := New("summary", "body")
+	noti.AddHint(HintCategory, "transfer")
+	noti.AddActionHandler("open", "Open", func() {})
+	noti.id = 42
+	clone := noti.Clone()
+	if clone.id != 0 {
+		t.Errorf("clone.id = %d, want 0", clone.id)
+	}
+	clone.AddHint(HintCategory, "email")
+	clone.AddHint(HintResident, true)
+	if v := noti.hints[HintCategory].Value(); v != "transfer" {
+		t.Errorf("hint of original changed to %v", v)
+	}
+	if _, ok := noti.hints[HintResident]; ok {
+		t.Error("hint added to clone was added to original")
+	}
+	clone.actions[0].Name = "Show"
+	clone.AddActionHandler("cancel", "Cancel", func() {})
+	if noti.actions[0].Name != "Open" || len(noti.actions) != 1 {
+		t.Errorf("actions of original changed to %v", noti.actions)
+	}
+}
+
+func TestResetHintsFrom(t *testing.T) {
+	template := New("", "")
+	template.AddHint(HintCategory, "transfer")
+	noti := New("", "")
+	noti.AddHint(HintResident, true)
+	noti.ResetHintsFrom(template)
+	if _, ok := noti.hints[HintResident]; ok {
+		t.Error("old hint was not removed")
+	}
+	noti.AddHint(HintCategory, "email")
+	if v := template.hints[HintCategory].Value(); v != "transfer" {
+		t.Errorf("hint of template changed to %v", v)
+	}
+}
+
+// setupTracking prepares the package state which is needed to track
+// notifications without a connection to the session bus and returns a
+// function which restores it.
+func setupTracking() func() {
+	oldNotifications, oldDispatch := notifications, dispatch
+	notifications = make(map[uint32]*Notification)
+	dispatch = runHandler
+	return func() {
+		notifications, dispatch = oldNotifications, oldDispatch
+	}
+}
+
+// sendFake tracks noti as if it was sent and the server assigned id.
+func sendFake(noti *Notification, id uint32, timeout time.Duration) {
+	mu.Lock()
+	oldID := noti.id
+	noti.id = id
+	noti.track(oldID)
+	noti.startExpireTimer(timeout)
+	mu.Unlock()
+}
+
+func TestExpireHandler(t *testing.T) {
+	defer setupTracking()()
+	expired := make(chan struct{}, 1)
+	noti := New("summary", "body")
+	noti.SetExpireHandler(func() { expired <- struct{}{} })
+	sendFake(noti, 1, 10*time.Millisecond)
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("expire handler was not called")
+	}
+}
+
+func TestExpireHandlerCancelledOnClose(t *testing.T) {
+	defer setupTracking()()
+	expired := make(chan struct{}, 1)
+	noti := New("summary", "body")
+	noti.SetExpireHandler(func() { expired <- struct{}{} })
+	sendFake(noti, 1, 20*time.Millisecond)
+	notificationClosedHandler(1, ReasonDismissed)
+	mu.Lock()
+	_, tracked := notifications[1]
+	timer := noti.expireTimer
+	mu.Unlock()
+	if tracked {
+		t.Error("closed notification is still tracked")
+	}
+	if timer != nil {
+		t.Error("expire timer was not stopped")
+	}
+	select {
+	case <-expired:
+		t.Error("expire handler was called after close")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestExpireHandlerNotStarted(t *testing.T) {
+	defer setupTracking()()
+	noti := New("summary", "body")
+	noti.SetExpireHandler(func() {})
+	for _, timeout := range []time.Duration{ExpiresNever, ExpiresDefault} {
+		sendFake(noti, 1, timeout)
+		mu.Lock()
+		timer := noti.expireTimer
+		mu.Unlock()
+		if timer != nil {
+			t.Errorf("expire timer started for timeout %v", timeout)
+		}
+	}
+}
+
+func TestActionsAfterCloseAndResend(t *testing.T) {
+	defer setupTracking()()
+	var invoked int
+	noti := New("summary", "body")
+	noti.AddActionHandler("open", "Open", func() { invoked++ })
+	sendFake(noti, 1, ExpiresDefault)
+	notificationClosedHandler(1, ReasonDismissed)
+	if noti.IsActive() {
+		t.Error("closed notification is still active")
+	}
+	sendFake(noti, 2, ExpiresDefault)
+	dropped := DroppedSignals()
+	actionInvokedHandler(1, "open")
+	if invoked != 0 {
+		t.Error("action handler was called for the old ID")
+	}
+	if DroppedSignals() != dropped+1 {
+		t.Error("signal for the old ID was not dropped")
+	}
+	actionInvokedHandler(2, "open")
+	if invoked != 1 {
+		t.Errorf("action handler was called %d times for the new ID, want 1", invoked)
+	}
+}
+
+func TestReasonString(t *testing.T) {
+	tests := []struct {
+		reason Reason
+		want   string
+	}{
+		{ReasonExpired, "expired"},
+		{ReasonDismissed, "dismissed"},
+		{ReasonClosed, "closed"},
+		{ReasonUndefined, "undefined"},
+		{7, "undefined(7)"},
+	}
+	for _, tt := range tests {
+		if got := tt.reason.String(); got != tt.want {
+			t.Errorf("Reason(%d).String() = %q, want %q", uint32(tt.reason), got, tt.want)
+		}
+	}
+}
+
+func TestUnknownReasonPassedThrough(t *testing.T) {
+	defer setupTracking()()
+	var got uint32
+	noti := New("summary", "body")
+	noti.SetClosedHandler(func(reason uint32) { got = reason })
+	sendFake(noti, 1, ExpiresDefault)
+	notificationClosedHandler(1, 7)
+	if got != 7 {
+		t.Errorf("closed handler got reason %d, want 7", got)
+	}
+}
+
+func TestCheckActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []Action
+		icons   bool
+		ok      bool
+	}{
+		{"none", nil, false, true},
+		{"unique", []Action{{Key: "open", Name: "Open"}, {Key: "close", Name: "Close"}}, false, true},
+		{"duplicate", []Action{{Key: "open", Name: "Open"}, {Key: "open", Name: "Show"}}, false, false},
+		{"default without name", []Action{{Key: "default"}}, false, true},
+		{"without name", []Action{{Key: "open"}}, false, false},
+		{"icon names without action-icons",
+			[]Action{{Key: "play", Name: "Play", IconName: "media"}, {Key: "next", Name: "Next", IconName: "media"}},
+			false, true},
+		{"duplicate icon names",
+			[]Action{{Key: "play", Name: "Play", IconName: "media"}, {Key: "next", Name: "Next", IconName: "media"}},
+			true, false},
+		{"icon name equals key",
+			[]Action{{Key: "play", Name: "Play"}, {Key: "next", Name: "Next", IconName: "play"}},
+			true, false},
+	}
+	for _, tt := range tests {
+		noti := New("", "")
+		noti.SetActions(tt.actions)
+		noti.SetHintBool(HintActionIcons, tt.icons)
+		err := noti.checkActions()
+		if tt.ok && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+// startLoop starts an event loop which dispatches handler functions
+// according to cfg and returns a function which stops it.
+func startLoop(cfg config) func() {
+	oldDispatch := dispatch
+	dispatch = newDispatcher(cfg)
+	c := make(chan *dbus.Signal, sigBufferSize)
+	wake := make(chan struct{}, 1)
+	done := make(chan struct{})
+	mu.Lock()
+	loopWake = wake
+	mu.Unlock()
+	go signalLoop(c, make(chan chan struct{}), wake, done)
+	return func() {
+		close(c)
+		<-done
+		dispatch = oldDispatch
+	}
+}
+
+func TestWorkerPoolNestedPost(t *testing.T) {
+	defer startLoop(config{workers: 1})()
+	ran := make(chan struct{}, 3)
+	returned := make(chan struct{})
+	post(func() {
+		for i := 0; i < cap(ran); i++ {
+			post(func() { ran <- struct{}{} })
+		}
+		close(returned)
+	})
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("handler blocked in post")
+	}
+	for i := 0; i < cap(ran); i++ {
+		select {
+		case <-ran:
+		case <-time.After(time.Second):
+			t.Fatalf("%d of %d posted functions ran", i, cap(ran))
+		}
+	}
+}
+
+func TestEmptyActionKeyPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(noti *Notification)
+	}{
+		{"AddActionHandler", func(noti *Notification) { noti.AddActionHandler("", "Open", func() {}) }},
+		{"SetActions", func(noti *Notification) {
+			noti.SetActions([]Action{{Key: "open", Name: "Open"}, {Key: "", Name: "Close"}})
+		}},
+	}
+	for _, tt := range tests {
+		noti := New("", "")
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s with an empty key did not panic", tt.name)
+				}
+			}()
+			tt.f(noti)
+		}()
+		if len(noti.actions) != 0 {
+			t.Errorf("%s with an empty key added actions: %v", tt.name, noti.actions)
+		}
+	}
+}
+
+func TestTrackRemovesStaleID(t *testing.T) {
+	defer setupTracking()()
+	noti := New("summary", "body")
+	sendFake(noti, 1, ExpiresDefault)
+	mu.Lock()
+	done := noti.done
+	mu.Unlock()
+	// the server did not know ID 1 anymore and returned a new ID
+	sendFake(noti, 2, ExpiresDefault)
+	mu.Lock()
+	_, stale := notifications[1]
+	current := notifications[2]
+	sameDone := noti.done == done
+	mu.Unlock()
+	if stale {
+		t.Error("stale ID 1 is still tracked")
+	}
+	if current != noti {
+		t.Error("new ID 2 is not tracked")
+	}
+	if !sameDone {
+		t.Error("done channel was replaced")
+	}
+	select {
+	case <-done:
+		t.Error("done channel was closed")
+	default:
+	}
+}
+
+func TestCloneWhileClosed(t *testing.T) {
+	defer setupTracking()()
+	noti := New("summary", "body")
+	noti.SetExpireHandler(func() {})
+	sendFake(noti, 1, time.Hour)
+	closed := make(chan struct{})
+	go func() {
+		notificationClosedHandler(1, ReasonDismissed)
+		close(closed)
+	}()
+	clone := noti.Clone()
+	<-closed
+	if clone.done != nil || clone.expireTimer != nil {
+		t.Error("clone shares the tracking state of the original")
+	}
+}
+
+func TestHandleSignalChecksSenderAndPath(t *testing.T) {
+	defer setupTracking()()
+	mu.Lock()
+	oldOwner := serverOwner
+	serverOwner = ":1.5"
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		serverOwner = oldOwner
+		mu.Unlock()
+	}()
+	var invoked int
+	noti := New("summary", "body")
+	noti.AddActionHandler("open", "Open", func() { invoked++ })
+	sendFake(noti, 1, ExpiresDefault)
+	tests := []struct {
+		sender string
+		path   dbus.ObjectPath
+		name   string
+		want   int
+	}{
+		{":1.7", objPath, busInterface + ".ActionInvoked", 0},
+		{":1.5", "/org/example/Other", busInterface + ".ActionInvoked", 0},
+		{":1.5", objPath, "org.example.Other.ActionInvoked", 0},
+		{":1.5", objPath, busInterface + ".ActionInvoked", 1},
+	}
+	for _, tt := range tests {
+		invoked = 0
+		handleSignal(&dbus.Signal{Sender: tt.sender, Path: tt.path, Name: tt.name,
+			Body: []interface{}{uint32(1), "open"}})
+		if invoked != tt.want {
+			t.Errorf("%s from %s on %s: handler called %d times, want %d",
+				tt.name, tt.sender, tt.path, invoked, tt.want)
+		}
+	}
+}
+
+func TestQuirkTimeout(t *testing.T) {
+	q := quirks["gnome-shell"]
+	tests := []struct {
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{ExpiresDefault, ExpiresDefault},
+		{ExpiresNever, ExpiresNever},
+		{5 * time.Second, ExpiresDefault},
+	}
+	for _, tt := range tests {
+		timeout := tt.timeout
+		q.apply(map[string]dbus.Variant{}, &timeout)
+		if timeout != tt.want {
+			t.Errorf("apply() with timeout %v = %v, want %v", tt.timeout, timeout, tt.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Notifier is the interface implemented by types which can send
+// notifications. It allows consumers to replace the D-Bus implementation
+// with a fake in tests.
+type Notifier interface {
+	Notify(noti *Notification) error
+	CloseNotification(noti *Notification) error
+	GetCapabilities() ([]string, error)
+	GetServerInformation() (*ServerInfo, error)
+}
+
+// DBusNotifier is a Notifier which uses the package level functions,
+// i.e. the D-Bus connection established with Init().
+type DBusNotifier struct{}
+
+// Notify calls Notify().
+func (DBusNotifier) Notify(noti *Notification) error {
+	return Notify(noti)
+}
+
+// CloseNotification calls CloseNotification().
+func (DBusNotifier) CloseNotification(noti *Notification) error {
+	return CloseNotification(noti)
+}
+
+// GetCapabilities calls GetCapabilities().
+func (DBusNotifier) GetCapabilities() ([]string, error) {
+	return GetCapabilities()
+}
+
+// GetServerInformation calls GetServerInformation().
+func (DBusNotifier) GetServerInformation() (*ServerInfo, error) {
+	return GetServerInformation()
+}
+
+// MultiNotifier returns a Notifier which sends notifications to all
+// notifiers, e.g. a DBusNotifier and a TerminalNotifier as fallback.
+// Notify() and CloseNotification() call all notifiers and return the errors
+// of the failed ones combined into a MultiError. GetCapabilities() and
+// GetServerInformation() return the result of the first notifier which
+// succeeds.
+func MultiNotifier(ns ...Notifier) Notifier {
+	return multiNotifier(ns)
+}
+
+// MultiError is returned by a MultiNotifier if one or more notifiers failed.
+type MultiError []error
+
+func (me MultiError) Error() string {
+	msgs := make([]string, len(me))
+	for i, err := range me {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether one of the errors matches target, so errors.Is()
+// can be used with a MultiError, e.g. to check for ErrUnsupported.
+func (me MultiError) Is(target error) bool {
+	for _, err := range me {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+type multiNotifier []Notifier
+
+func (mn multiNotifier) each(f func(Notifier) error) error {
+	var errs MultiError
+	for _, n := range mn {
+		if err := f(n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (mn multiNotifier) Notify(noti *Notification) error {
+	return mn.each(func(n Notifier) error { return n.Notify(noti) })
+}
+
+func (mn multiNotifier) CloseNotification(noti *Notification) error {
+	return mn.each(func(n Notifier) error { return n.CloseNotification(noti) })
+}
+
+func (mn multiNotifier) GetCapabilities() (result []string, err error) {
+	err = fmt.Errorf("notification: No notifiers")
+	for _, n := range mn {
+		result, err = n.GetCapabilities()
+		if err == nil {
+			break
+		}
+	}
+	return
+}
+
+func (mn multiNotifier) GetServerInformation() (info *ServerInfo, err error) {
+	err = fmt.Errorf("notification: No notifiers")
+	for _, n := range mn {
+		info, err = n.GetServerInformation()
+		if err == nil {
+			break
+		}
+	}
+	return
+}
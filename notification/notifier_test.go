@@ -0,0 +1,20 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMultiErrorIs(t *testing.T) {
+	err := error(MultiError{
+		errors.New("first"),
+		fmt.Errorf("%w: %v", ErrUnsupported, "second"),
+	})
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("errors.Is(%v, ErrUnsupported) = false", err)
+	}
+	if errors.Is(err, ErrNoServer) {
+		t.Errorf("errors.Is(%v, ErrNoServer) = true", err)
+	}
+}
@@ -0,0 +1,41 @@
+package notification
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/godbus/dbus"
+)
+
+// OpenURI opens a URI with the user's default application, e.g. from an
+// action handler. It uses the OpenURI portal (org.freedesktop.portal.OpenURI)
+// and falls back to running xdg-open if the portal is not available.
+// File paths and file:// URIs are always opened with xdg-open because the
+// portal does not support them. URIs starting with "-" are rejected,
+// so they cannot be mistaken for options of xdg-open.
+func OpenURI(uri string) error {
+	if uri == "" || strings.HasPrefix(uri, "-") {
+		return fmt.Errorf("notification: Invalid URI: %q", uri)
+	}
+	if strings.Contains(uri, "://") && !strings.HasPrefix(uri, "file://") {
+		conn := busConn
+		if conn == nil {
+			conn, _ = dbus.SessionBus()
+		}
+		if conn != nil {
+			obj := conn.Object(portalBusName, portalObjPath)
+			err := obj.Call("org.freedesktop.portal.OpenURI.OpenURI", 0,
+				"", uri, map[string]dbus.Variant{}).Err
+			if err == nil {
+				return nil
+			}
+		}
+	}
+	cmd := exec.Command("xdg-open", uri)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("notification: %w", err)
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,254 @@
+package notification
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus"
+)
+
+// Option is an option for Init() and InitContext().
+type Option func(*config)
+
+type config struct {
+	workers          int
+	autoDesktopEntry bool
+	fallback         Notifier
+	logger           *log.Logger
+	criticalNoExpire bool
+	portal           bool
+	destination      string
+	syncHandlers     bool
+	errors           chan<- error
+	signals          chan *dbus.Signal
+	quirks           bool
+	coalesce         time.Duration
+}
+
+// WithWorkerPool limits the number of concurrently running handler
+// functions to size. If all workers are busy, the event loop waits until
+// one is available, so a slow handler delays the processing of further
+// signals. Handler functions for other events, e.g. expire handlers, are
+// passed to the event loop as well, so the code which triggers them, e.g.
+// Notify() called from a handler, never waits for a worker.
+// By default each handler function is executed in its own goroutine.
+func WithWorkerPool(size int) Option {
+	return func(cfg *config) {
+		cfg.workers = size
+	}
+}
+
+// WithAutoDesktopEntry sets the "desktop-entry" hint of every notification
+// which does not set it itself. Inside a Flatpak sandbox the app ID is used
+// (see SandboxAppID()), otherwise the name of the desktop entry is derived
+// from the name of the executable or, if that is not available, from the
+// application name passed to Init(). A default set with
+// SetDefaultDesktopEntry() is not replaced, and the derived value is
+// removed when Init() is called again without this option.
+// This helps e.g. GNOME Shell to group notifications and find the app's icon.
+func WithAutoDesktopEntry() Option {
+	return func(cfg *config) {
+		cfg.autoDesktopEntry = true
+	}
+}
+
+// WithTerminalFallback makes Init() succeed even if the connection to the
+// session bus fails. In this case notifications are written to w by a
+// TerminalNotifier; if w is nil, os.Stderr will be used.
+func WithTerminalFallback(w io.Writer) Option {
+	return func(cfg *config) {
+		cfg.fallback = TerminalNotifier{w}
+	}
+}
+
+// WithLogger sets a logger for warnings, e.g. about settings which the
+// server will probably not honor. By default nothing is logged.
+func WithLogger(logger *log.Logger) Option {
+	return func(cfg *config) {
+		cfg.logger = logger
+	}
+}
+
+// WithCriticalNeverExpires sends notifications with UrgencyCritical
+// and a finite timeout as ExpiresNever. Many servers ignore the timeout of
+// critical notifications and keep them until they are dismissed; whether
+// a server does so is not defined by the specification. Without this option
+// a warning is logged for such notifications (see WithLogger()).
+func WithCriticalNeverExpires() Option {
+	return func(cfg *config) {
+		cfg.criticalNoExpire = true
+	}
+}
+
+// WithPortal sends notifications through the notification portal
+// (org.freedesktop.portal.Notification) if no notification server is
+// available directly, e.g. inside a Flatpak sandbox.
+// The portal has no NotificationClosed signal, so closed handlers are never
+// called; it does not support hints, timeouts and icon files; and
+// GetCapabilities() and GetServerInformation() return ErrUnsupported.
+func WithPortal() Option {
+	return func(cfg *config) {
+		cfg.portal = true
+	}
+}
+
+// WithDestination sends method calls to the given bus name instead of
+// org.freedesktop.Notifications, e.g. the unique name (":1.42") of a
+// specific notification server. Signals are only received from this name
+// as well; for a well-known name the bus matches its current owner.
+func WithDestination(name string) Option {
+	return func(cfg *config) {
+		cfg.destination = name
+	}
+}
+
+// WithSyncHandlers executes handler functions one after another in the
+// event loop instead of in goroutines. This includes the handlers for
+// events which do not come from a signal, e.g. expire handlers and the
+// first call of the function passed to OnServerAvailability(); they are
+// passed to the event loop and run after the function which triggered them
+// has returned. So handler functions do not run concurrently with each
+// other while the event loop is running; after it has stopped (see Sync())
+// they are executed in goroutines.
+// Handlers run in the order in which the event loop receives the signals,
+// but godbus delivers each signal from its own goroutine, so this is not
+// necessarily the order in which the server emitted them. A handler must
+// not block (e.g. by calling Sync()) because no further signals are
+// processed until it returns.
+func WithSyncHandlers() Option {
+	return func(cfg *config) {
+		cfg.syncHandlers = true
+	}
+}
+
+// WithErrorChannel sets a channel for errors which occur in the event loop,
+// e.g. a panic in a handler function (which is recovered). Errors are
+// dropped if the channel is full.
+func WithErrorChannel(ch chan<- error) Option {
+	return func(cfg *config) {
+		cfg.errors = ch
+	}
+}
+
+// WithSignalChannel makes the event loop read signals from ch instead of
+// a channel created by Init(). The caller is responsible for delivering
+// the signals to ch, e.g. by registering it with BusConn().Signal(ch) after
+// Init() has returned, or by forwarding them from its own channel. Init()
+// adds the match rules for the signals only on its own connection (see
+// BusConn()); a connection of the caller needs the same match rules.
+// Signals which do not come from the notification server or the bus,
+// e.g. those of other services, are ignored.
+// The event loop stops when ch is closed. If Init() is called again, its
+// previous connection is closed, which also closes the channels registered
+// on it; so a channel registered with BusConn().Signal() cannot be passed
+// to Init() again.
+func WithSignalChannel(ch chan *dbus.Signal) Option {
+	return func(cfg *config) {
+		cfg.signals = ch
+	}
+}
+
+// WithQuirks enables workarounds for known deviations of some servers
+// from the specification. The server is identified by the name returned by
+// GetServerInformation() or, if that fails, by the environment variable
+// XDG_CURRENT_DESKTOP. The workarounds are:
+//   - KDE-specific hints ("x-kde-*") are removed for servers known not to
+//     support them (e.g. gnome-shell, dunst)
+//   - positive timeouts are sent as ExpiresDefault to servers known to
+//     ignore them (gnome-shell, notify-osd) and a warning is logged
+//
+// By default notifications are sent as they are.
+func WithQuirks() Option {
+	return func(cfg *config) {
+		cfg.quirks = true
+	}
+}
+
+// WithCoalesce coalesces identical notifications: if a new notification
+// (one which was not sent before) has the same application name, summary
+// and body as a notification sent within window which is still active,
+// it replaces that notification instead of showing a second one.
+// The replaced Notification is no longer tracked, i.e. its handlers are
+// not called anymore.
+func WithCoalesce(window time.Duration) Option {
+	return func(cfg *config) {
+		cfg.coalesce = window
+	}
+}
+
+func logf(format string, args ...interface{}) {
+	if conf.logger != nil {
+		conf.logger.Printf("notification: "+format, args...)
+	}
+}
+
+func autoDesktopEntry(appName string) string {
+	if id := SandboxAppID(); id != "" {
+		return id
+	}
+	if len(os.Args) > 0 && os.Args[0] != "" {
+		return strings.TrimSuffix(filepath.Base(os.Args[0]), ".desktop")
+	}
+	return appName
+}
+
+func newConfig(opts []Option) config {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// newDispatcher returns a function which executes handler functions
+// according to the configuration.
+func newDispatcher(cfg config) func(func()) {
+	if cfg.syncHandlers {
+		return runHandler
+	}
+	if cfg.workers <= 0 {
+		return func(f func()) {
+			go runHandler(f)
+		}
+	}
+	jobs := make(chan func(), cfg.workers)
+	for i := 0; i < cfg.workers; i++ {
+		go func() {
+			for f := range jobs {
+				runHandler(f)
+			}
+		}()
+	}
+	return func(f func()) {
+		jobs <- f
+	}
+}
+
+// runHandler executes a handler function and reports a panic as an error.
+func runHandler(f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			reportError(fmt.Errorf("notification: Handler panicked: %v", r))
+		}
+	}()
+	f()
+}
+
+// reportError logs an error and sends it to the error channel
+// unless the channel is full.
+func reportError(err error) {
+	if conf.logger != nil {
+		conf.logger.Print(err)
+	}
+	if conf.errors != nil {
+		select {
+		case conf.errors <- err:
+		default:
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package notification
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/godbus/dbus"
+)
+
+// The notification portal differs from org.freedesktop.Notifications:
+//   - the IDs of notifications are chosen by the application
+//   - there is no NotificationClosed signal, so closed handlers are never
+//     called and notifications stay active until they are closed with
+//     CloseNotification()
+//   - hints, timeouts and the application name are not supported
+//   - only icon names from the icon theme are supported
+//   - GetCapabilities() and GetServerInformation() return ErrUnsupported
+// See https://flatpak.github.io/xdg-desktop-portal/docs/doc-org.freedesktop.portal.Notification.html
+
+const (
+	portalBusName   = "org.freedesktop.portal.Desktop"
+	portalObjPath   = "/org/freedesktop/portal/desktop"
+	portalInterface = "org.freedesktop.portal.Notification"
+)
+
+var portalLastID uint32 // guarded by mu
+
+// portalIcon is a serialized GIcon.
+type portalIcon struct {
+	Type  string
+	Value dbus.Variant
+}
+
+var portalPriorities = map[Urgency]string{
+	UrgencyLow:      "low",
+	UrgencyNormal:   "normal",
+	UrgencyCritical: "urgent",
+}
+
+func portalNotify(ctx context.Context, noti *Notification, icon string) error {
+	mu.Lock()
+	if noti.id == 0 {
+		portalLastID++
+		noti.id = portalLastID
+	}
+	mu.Unlock()
+	n := map[string]dbus.Variant{
+		"title": dbus.MakeVariant(noti.summary),
+		"body":  dbus.MakeVariant(noti.body),
+	}
+	if priority, ok := portalPriorities[noti.urgency]; ok {
+		n["priority"] = dbus.MakeVariant(priority)
+	}
+	if icon != "" && isIconName(icon) {
+		n["icon"] = dbus.MakeVariant(portalIcon{"themed", dbus.MakeVariant([]string{icon})})
+	}
+	var buttons []map[string]dbus.Variant
+	for _, action := range noti.actions {
+		if action.Key == "default" {
+			n["default-action"] = dbus.MakeVariant(action.Key)
+			continue
+		}
+		buttons = append(buttons, map[string]dbus.Variant{
+			"label":  dbus.MakeVariant(action.Name),
+			"action": dbus.MakeVariant(action.Key),
+		})
+	}
+	if len(buttons) > 0 {
+		n["buttons"] = dbus.MakeVariant(buttons)
+	}
+	err := callContext(ctx, busObj, portalInterface+".AddNotification", portalID(noti.id), n).Err
+	if err != nil {
+		return callError(err)
+	}
+	mu.Lock()
+	noti.track(noti.id)
+	lifecycle := noti.lifecycleHandler
+	mu.Unlock()
+	postLifecycleEvent(lifecycle, LifecycleEvent{Phase: LifecycleSent, ID: noti.id})
+	return nil
+}
+
+func portalClose(id uint32) error {
+	err := busObj.Call(portalInterface+".RemoveNotification", 0, portalID(id)).Err
+	if err != nil {
+		return callError(err)
+	}
+	mu.Lock()
+	if noti, ok := notifications[id]; ok {
+		noti.untrack(id)
+	}
+	mu.Unlock()
+	return nil
+}
+
+func portalID(id uint32) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func portalActionInvoked(sig *dbus.Signal) {
+	s, ok := sig.Body[0].(string)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return
+	}
+	if key, ok := sig.Body[1].(string); ok {
+		actionInvokedHandler(uint32(id), key)
+	}
+}
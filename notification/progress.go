@@ -0,0 +1,53 @@
+package notification
+
+import (
+	"sync"
+	"time"
+)
+
+// ProgressNotifier updates a notification with a progress bar (see
+// SetProgress()) without flooding the server: the notification is only
+// sent again when the percentage has changed and at least the minimum
+// interval has elapsed since the last update. Reaching 100 percent is
+// always sent.
+type ProgressNotifier struct {
+	noti        *Notification
+	minInterval time.Duration
+	mu          sync.Mutex
+	percent     int
+	last        time.Time
+}
+
+// NewProgressNotifier creates a ProgressNotifier for noti.
+// With a minInterval of 0 every change of the percentage is sent.
+func NewProgressNotifier(noti *Notification, minInterval time.Duration) *ProgressNotifier {
+	return &ProgressNotifier{noti: noti, minInterval: minInterval, percent: -1}
+}
+
+// Update computes the percentage from done and total, clamped to the range
+// 0 to 100, and sends the notification if necessary.
+func (pn *ProgressNotifier) Update(done, total int64) error {
+	percent := 0
+	if total > 0 {
+		percent = int(float64(done) / float64(total) * 100)
+	}
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	pn.mu.Lock()
+	defer pn.mu.Unlock()
+	if percent == pn.percent ||
+		(percent < 100 && time.Since(pn.last) < pn.minInterval) {
+		return nil
+	}
+	pn.noti.SetProgress(percent)
+	err := Notify(pn.noti)
+	if err != nil {
+		return err
+	}
+	pn.percent = percent
+	pn.last = time.Now()
+	return nil
+}
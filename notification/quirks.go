@@ -0,0 +1,72 @@
+package notification
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus"
+)
+
+// quirk describes the known deviations of a server.
+type quirk struct {
+	stripPrefixes  []string // prefixes of hints the server does not support
+	ignoresTimeout bool     // the server uses its own timeouts
+}
+
+// Quirks of servers by the name returned by GetServerInformation().
+var quirks = map[string]quirk{
+	"gnome-shell":   {stripPrefixes: []string{"x-kde-"}, ignoresTimeout: true},
+	"notify-osd":    {stripPrefixes: []string{"x-kde-"}, ignoresTimeout: true},
+	"dunst":         {stripPrefixes: []string{"x-kde-"}},
+	"xfce4-notifyd": {stripPrefixes: []string{"x-kde-"}},
+}
+
+// Server names by the value of XDG_CURRENT_DESKTOP, used if the server
+// information is not available.
+var desktopServers = map[string]string{
+	"GNOME": "gnome-shell",
+	"Unity": "notify-osd",
+	"XFCE":  "xfce4-notifyd",
+}
+
+var activeQuirk *quirk // set by Init() with WithQuirks()
+
+// lookupQuirk returns the quirks of the running server or nil if there
+// are none.
+func lookupQuirk(ctx context.Context) *quirk {
+	var name string
+	if info, err := GetServerInformationContext(ctx); err == nil {
+		name = info.Name
+	} else {
+		for _, desktop := range strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":") {
+			if server, ok := desktopServers[desktop]; ok {
+				name = server
+				break
+			}
+		}
+	}
+	q, ok := quirks[name]
+	if !ok {
+		return nil
+	}
+	return &q
+}
+
+// apply removes unsupported hints and replaces a timeout which the server
+// will ignore with ExpiresDefault. The expire handler (see
+// SetExpireHandler()) still uses the notification's timeout.
+func (q *quirk) apply(hints map[string]dbus.Variant, timeout *time.Duration) {
+	for key := range hints {
+		for _, prefix := range q.stripPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				delete(hints, key)
+			}
+		}
+	}
+	if q.ignoresTimeout && *timeout > 0 {
+		logf("Timeout %v is ignored by the server", *timeout)
+		*timeout = ExpiresDefault
+	}
+}
@@ -0,0 +1,114 @@
+package notification
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus"
+)
+
+// SendOption is an option for Send().
+type SendOption func(*sendConfig)
+
+type sendConfig struct {
+	appName   string
+	appIcon   string
+	urgency   Urgency
+	timeout   time.Duration
+	replaceID uint32
+	noReply   bool
+}
+
+// WithAppName sets the application name.
+func WithAppName(appName string) SendOption {
+	return func(cfg *sendConfig) {
+		cfg.appName = appName
+	}
+}
+
+// WithAppIcon sets the application icon.
+func WithAppIcon(appIcon string) SendOption {
+	return func(cfg *sendConfig) {
+		cfg.appIcon = appIcon
+	}
+}
+
+// WithUrgency sets the urgency level (default: UrgencyNormal).
+func WithUrgency(urgency Urgency) SendOption {
+	return func(cfg *sendConfig) {
+		cfg.urgency = urgency
+	}
+}
+
+// WithTimeout sets the expiration timeout. It is rounded up to whole
+// milliseconds and capped at the largest value the server accepts.
+func WithTimeout(timeout time.Duration) SendOption {
+	return func(cfg *sendConfig) {
+		cfg.timeout = timeout
+	}
+}
+
+// WithNeverExpire lets the notification never expire.
+func WithNeverExpire() SendOption {
+	return WithTimeout(ExpiresNever)
+}
+
+// WithServerDefault uses the server's default timeout. This is the default.
+func WithServerDefault() SendOption {
+	return WithTimeout(ExpiresDefault)
+}
+
+// WithReplaceID sets the ID of a notification which will be replaced,
+// e.g. the ID returned by an earlier call of Send().
+// If the server does not know the ID a new notification will be shown.
+func WithReplaceID(id uint32) SendOption {
+	return func(cfg *sendConfig) {
+		cfg.replaceID = id
+	}
+}
+
+// WithNoReply sends the notification without waiting for the reply of the
+// server. This saves a round-trip, but Send() will return 0 as the ID and
+// errors from the server will not be reported. For notifications sent with
+// Notify() see (*Notification).SetNoReply().
+func WithNoReply() SendOption {
+	return func(cfg *sendConfig) {
+		cfg.noReply = true
+	}
+}
+
+// Send sends a simple notification and returns its ID.
+// Like SendNotification() it does not require Init() and
+// signals for the notification will not be handled.
+func Send(summary, body string, opts ...SendOption) (uint32, error) {
+	cfg := sendConfig{urgency: UrgencyNormal, timeout: ExpiresDefault}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return 0, fmt.Errorf("notification: Failed to connect to session bus: %w", err)
+	}
+	obj := conn.Object(busName, objPath)
+	hints := make(map[string]dbus.Variant, 1)
+	if cfg.urgency != UrgencyUnset {
+		hints["urgency"] = dbus.MakeVariant(cfg.urgency)
+	}
+	var flags dbus.Flags
+	if cfg.noReply {
+		flags = dbus.FlagNoReplyExpected
+	}
+	call := obj.Call(busInterface+".Notify", flags, cfg.appName, cfg.replaceID, resolveIcon(cfg.appIcon),
+		summary, body, make([]string, 0), hints, timeoutMillis(cfg.timeout))
+	if call.Err != nil {
+		return 0, callError(call.Err)
+	}
+	var id uint32
+	if !cfg.noReply {
+		err = call.Store(&id)
+		if err != nil {
+			return 0, callError(err)
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,197 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus"
+)
+
+const (
+	nameOwnerChanged  = "org.freedesktop.DBus.NameOwnerChanged"
+	propertiesChanged = "org.freedesktop.DBus.Properties.PropertiesChanged"
+)
+
+var (
+	availabilityHandlers []func(bool) // guarded by mu
+	inhibitedHandlers    []func(bool) // guarded by mu
+)
+
+// OnServerAvailability adds a function which is called when a notification
+// server starts (true) or stops (false), i.e. when the owner of the name
+// org.freedesktop.Notifications changes. The function is called with the
+// current state when it is added. If the match rule for the signal cannot
+// be added or the state cannot be read, the function is not added.
+func OnServerAvailability(handler func(available bool)) error {
+	if busConn == nil {
+		return fmt.Errorf("notification: D-Bus not initialized")
+	}
+	running, err := addAvailabilityHandler(handler)
+	if err != nil {
+		return err
+	}
+	post(func() { handler(running) })
+	return nil
+}
+
+// addAvailabilityHandler adds the match rule for the signal if necessary
+// and then the handler and returns whether a server is running.
+func addAvailabilityHandler(handler func(bool)) (bool, error) {
+	matchMu.Lock()
+	defer matchMu.Unlock()
+	mu.Lock()
+	first := len(availabilityHandlers) == 0
+	mu.Unlock()
+	if first {
+		err := addMatch(context.Background(), busConn, ownerMatchRule(busName))
+		if err != nil {
+			return false, callError(err)
+		}
+	}
+	running, err := serverRunning(context.Background(), busConn)
+	if err != nil {
+		return false, err
+	}
+	mu.Lock()
+	availabilityHandlers = append(availabilityHandlers, handler)
+	mu.Unlock()
+	return running, nil
+}
+
+func serverOwnerChanged(sig *dbus.Signal) {
+	if len(sig.Body) < 3 {
+		return
+	}
+	name, _ := sig.Body[0].(string)
+	newOwner, ok := sig.Body[2].(string)
+	if !ok {
+		return
+	}
+	mu.Lock()
+	if name == serverName {
+		serverOwner = newOwner
+	}
+	handlers := availabilityHandlers
+	mu.Unlock()
+	if name != busName {
+		return
+	}
+	for _, handler := range handlers {
+		handler := handler
+		dispatch(func() { handler(newOwner != "") })
+	}
+}
+
+// IsDoNotDisturb reports whether the server currently suppresses
+// notifications, e.g. because "do not disturb" mode is enabled or a
+// fullscreen application is running. Notify() still succeeds in this case.
+// The specification neither defines this state nor a way to confirm that a
+// notification was actually displayed. The property Inhibited of the
+// notification object is read, which is provided by KDE Plasma; for other
+// servers an error wrapping ErrUnsupported is returned. Other errors, e.g.
+// a timeout, mean that the call failed.
+func IsDoNotDisturb() (bool, error) {
+	if busObj == nil {
+		return false, fmt.Errorf("notification: D-Bus not initialized")
+	}
+	if usePortal {
+		return false, ErrUnsupported
+	}
+	v, err := busObj.GetProperty(busInterface + ".Inhibited")
+	if err != nil {
+		return false, propertyError(err)
+	}
+	inhibited, ok := v.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: Inhibited is %s", ErrUnsupported, v.Signature())
+	}
+	return inhibited, nil
+}
+
+// OnInhibitedChanged adds a function which is called when the server
+// starts (true) or stops (false) suppressing notifications (see
+// IsDoNotDisturb()), e.g. to defer non-critical notifications during a
+// presentation. The function is called with the current state when it is
+// added. Changes are observed with the PropertiesChanged signal of the
+// property Inhibited, so this only works with servers providing it, like
+// KDE Plasma; for other servers ErrUnsupported is returned. If an error
+// is returned, the function is not added.
+func OnInhibitedChanged(handler func(inhibited bool)) error {
+	if busObj == nil {
+		return fmt.Errorf("notification: D-Bus not initialized")
+	}
+	if usePortal {
+		return ErrUnsupported
+	}
+	inhibited, err := addInhibitedHandler(handler)
+	if err != nil {
+		return err
+	}
+	post(func() { handler(inhibited) })
+	return nil
+}
+
+// addInhibitedHandler adds the match rule for the signal if necessary and
+// then the handler and returns the current state.
+func addInhibitedHandler(handler func(bool)) (bool, error) {
+	matchMu.Lock()
+	defer matchMu.Unlock()
+	mu.Lock()
+	first := len(inhibitedHandlers) == 0
+	mu.Unlock()
+	if first {
+		rule := fmt.Sprintf("type='signal',sender='%s',path='%s',"+
+			"interface='org.freedesktop.DBus.Properties',member='PropertiesChanged',arg0='%s'",
+			busObj.Destination(), objPath, busInterface)
+		err := addMatch(context.Background(), busConn, rule)
+		if err != nil {
+			return false, callError(err)
+		}
+	}
+	inhibited, err := IsDoNotDisturb()
+	if err != nil {
+		return false, err
+	}
+	mu.Lock()
+	inhibitedHandlers = append(inhibitedHandlers, handler)
+	mu.Unlock()
+	return inhibited, nil
+}
+
+// propertyError wraps an error returned from reading a property. Errors
+// which mean that the server does not provide the property wrap
+// ErrUnsupported, others are handled by callError().
+func propertyError(err error) error {
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) {
+		switch dbusErr.Name {
+		case "org.freedesktop.DBus.Error.UnknownProperty", "org.freedesktop.DBus.Error.UnknownInterface",
+			"org.freedesktop.DBus.Error.InvalidArgs":
+			return fmt.Errorf("%w: %v", ErrUnsupported, err)
+		}
+	}
+	return callError(err)
+}
+
+func serverPropertiesChanged(sig *dbus.Signal) {
+	if iface, _ := sig.Body[0].(string); iface != busInterface {
+		return
+	}
+	changed, _ := sig.Body[1].(map[string]dbus.Variant)
+	v, ok := changed["Inhibited"]
+	if !ok {
+		return
+	}
+	inhibited, ok := v.Value().(bool)
+	if !ok {
+		return
+	}
+	mu.Lock()
+	handlers := inhibitedHandlers
+	mu.Unlock()
+	for _, handler := range handlers {
+		handler := handler
+		dispatch(func() { handler(inhibited) })
+	}
+}
@@ -0,0 +1,31 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus"
+)
+
+func TestPropertyError(t *testing.T) {
+	tests := []struct {
+		name        string
+		unsupported bool
+	}{
+		{"org.freedesktop.DBus.Error.UnknownProperty", true},
+		{"org.freedesktop.DBus.Error.UnknownInterface", true},
+		{"org.freedesktop.DBus.Error.InvalidArgs", true},
+		{"org.freedesktop.DBus.Error.UnknownMethod", true},
+		{"org.freedesktop.DBus.Error.NoReply", false},
+		{"org.freedesktop.DBus.Error.Timeout", false},
+	}
+	for _, tt := range tests {
+		err := propertyError(dbus.Error{Name: tt.name})
+		if errors.Is(err, ErrUnsupported) != tt.unsupported {
+			t.Errorf("propertyError(%s) = %v", tt.name, err)
+		}
+	}
+	if err := propertyError(errors.New("connection closed")); errors.Is(err, ErrUnsupported) {
+		t.Errorf("propertyError(connection closed) = %v", err)
+	}
+}
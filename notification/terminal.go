@@ -0,0 +1,56 @@
+package notification
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// TerminalNotifier is a Notifier which writes notifications to a terminal.
+// It can be used on headless systems or if no notification server is
+// available, e.g. in an SSH session.
+type TerminalNotifier struct {
+	Writer io.Writer // if nil, os.Stderr will be used
+}
+
+// Notify writes the notification's urgency, summary and body.
+func (tn TerminalNotifier) Notify(noti *Notification) error {
+	w := tn.Writer
+	if w == nil {
+		w = os.Stderr
+	}
+	var urgency string
+	switch noti.urgency {
+	case UrgencyLow:
+		urgency = "low"
+	case UrgencyCritical:
+		urgency = "CRITICAL"
+	default:
+		urgency = "normal"
+	}
+	text := fmt.Sprintf("[%s] %s\n", urgency, noti.summary)
+	if noti.body != "" {
+		text += "    " + strings.Replace(noti.body, "\n", "\n    ", -1) + "\n"
+	}
+	_, err := io.WriteString(w, text)
+	if err != nil {
+		return fmt.Errorf("notification: %w", err)
+	}
+	return nil
+}
+
+// CloseNotification does nothing.
+func (TerminalNotifier) CloseNotification(noti *Notification) error {
+	return nil
+}
+
+// GetCapabilities returns "body" as the only capability.
+func (TerminalNotifier) GetCapabilities() ([]string, error) {
+	return []string{"body"}, nil
+}
+
+// GetServerInformation returns information about the TerminalNotifier.
+func (TerminalNotifier) GetServerInformation() (*ServerInfo, error) {
+	return &ServerInfo{"terminal", "go-notification", PackageVersion, "1.2"}, nil
+}
@@ -0,0 +1,66 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+)
+
+type actionResult struct {
+	noti *Notification
+	key  string
+}
+
+// Channels of WaitForAnyAction() by notification; guarded by mu.
+var actionWaiters = make(map[*Notification][]chan<- actionResult)
+
+// WaitForAnyAction waits until an action of one of the notifications is
+// invoked and returns the notification and the action's key. The
+// notifications should have been sent with Notify() before.
+// The actions' handler functions are not changed and are called as usual.
+// If ctx is done first, the context's error is returned.
+func WaitForAnyAction(ctx context.Context, notis ...*Notification) (*Notification, string, error) {
+	ch := make(chan actionResult, 1)
+	mu.Lock()
+	for _, noti := range notis {
+		actionWaiters[noti] = append(actionWaiters[noti], ch)
+	}
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		for _, noti := range notis {
+			removeActionWaiter(noti, ch)
+		}
+		mu.Unlock()
+	}()
+	select {
+	case r := <-ch:
+		return r.noti, r.key, nil
+	case <-ctx.Done():
+		return nil, "", fmt.Errorf("notification: %w", ctx.Err())
+	}
+}
+
+// removeActionWaiter removes ch from the waiters of noti. mu must be held.
+func removeActionWaiter(noti *Notification, ch chan<- actionResult) {
+	waiters := actionWaiters[noti][:0]
+	for _, c := range actionWaiters[noti] {
+		if c != ch {
+			waiters = append(waiters, c)
+		}
+	}
+	if len(waiters) == 0 {
+		delete(actionWaiters, noti)
+	} else {
+		actionWaiters[noti] = waiters
+	}
+}
+
+// notifyActionWaiters passes an invoked action to WaitForAnyAction().
+func notifyActionWaiters(waiters []chan<- actionResult, noti *Notification, key string) {
+	for _, ch := range waiters {
+		select {
+		case ch <- actionResult{noti, key}:
+		default:
+		}
+	}
+}